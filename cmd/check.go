@@ -2,11 +2,15 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/naru-bot/upp/internal/alert"
 	"github.com/naru-bot/upp/internal/checker"
 	"github.com/naru-bot/upp/internal/config"
 	"github.com/naru-bot/upp/internal/db"
+	"github.com/naru-bot/upp/internal/diff"
+	"github.com/naru-bot/upp/internal/engine"
 	"github.com/naru-bot/upp/internal/notify"
 	"github.com/naru-bot/upp/internal/trigger"
 	"github.com/spf13/cobra"
@@ -20,15 +24,19 @@ func init() {
 
 Without arguments, checks all targets. With an argument, checks only the specified target.
 Use --tag to check only targets with a specific tag.
+Checks run concurrently; use --parallel to control how many run at once
+(defaults to concurrency.max from the config file).
 
 Examples:
   upp check
   upp check "My Site"
   upp check https://example.com
-  upp check --tag my-sites`,
+  upp check --tag my-sites
+  upp check --parallel 16`,
 		Run: runCheck,
 	}
 	cmd.Flags().String("tag", "", "Only check targets with this tag")
+	cmd.Flags().IntP("parallel", "P", 0, "Number of checks to run concurrently (default: concurrency.max)")
 	rootCmd.AddCommand(cmd)
 }
 
@@ -43,6 +51,15 @@ type checkOutput struct {
 	Triggered    *bool  `json:"triggered,omitempty"`
 	Error        string `json:"error,omitempty"`
 	SSLDaysLeft  *int   `json:"ssl_days_left,omitempty"`
+	Timing           *db.Timing              `json:"timing,omitempty"`
+	Ping             *pingStats              `json:"ping,omitempty"`
+	Steps            []stepOutput            `json:"steps,omitempty"`
+	FailedAssertions []string                `json:"failed_assertions,omitempty"`
+	NewItems         []db.FeedItem           `json:"new_items,omitempty"`
+	Sitemap          *checker.SitemapDiff    `json:"sitemap,omitempty"`
+	Value            *float64                `json:"value,omitempty"`
+	HeaderChanges    []checker.HeaderChange  `json:"header_changes,omitempty"`
+	Security         *checker.SecurityReport `json:"security,omitempty"`
 }
 
 func runCheck(cmd *cobra.Command, args []string) {
@@ -78,37 +95,34 @@ func runCheck(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	var outputs []checkOutput
+	parallel, _ := cmd.Flags().GetInt("parallel")
+	if parallel <= 0 {
+		parallel = config.Get().MaxConcurrency()
+	}
 
+	var active []db.Target
 	for _, t := range targets {
-		if t.Paused {
-			continue
+		if !t.Paused {
+			active = append(active, t)
 		}
+	}
 
-		if !jsonOutput && !quiet {
-			fmt.Printf("  ⟳ Checking %s...\r", t.Name)
-		}
+	// Results arrive in completion order; keep JSON output in target order.
+	index := make(map[int64]int, len(active))
+	for i, t := range active {
+		index[t.ID] = i
+	}
+	outputs := make([]checkOutput, len(active))
+	remaining := len(active)
 
-		result := checker.Check(&t)
+	if !jsonOutput && !quiet && remaining > 0 {
+		fmt.Printf("  ⟳ Checking %d target(s)...\r", remaining)
+	}
 
-		// Save check result
-		cr := &db.CheckResult{
-			TargetID:     t.ID,
-			Status:       result.Status,
-			StatusCode:   result.StatusCode,
-			ResponseTime: result.ResponseTime.Milliseconds(),
-			ContentHash:  result.ContentHash,
-			Error:        result.Error,
-		}
-		db.SaveCheckResult(cr)
-
-		// Save snapshot if content available
-		if result.Content != "" && result.ContentHash != "" {
-			snaps, _ := db.GetLatestSnapshots(t.ID, 1)
-			if len(snaps) == 0 || snaps[0].Hash != result.ContentHash {
-				db.SaveSnapshot(t.ID, result.Content, result.ContentHash)
-			}
-		}
+	eng := engine.New(parallel, config.Get().ExpensiveConcurrency())
+	eng.Run(active, func(t db.Target, result *checker.Result) {
+		triggered := recordResult(&t, result)
+		remaining--
 
 		out := checkOutput{
 			Target:      t.Name,
@@ -118,7 +132,17 @@ func runCheck(cmd *cobra.Command, args []string) {
 			ResponseMs:  result.ResponseTime.Milliseconds(),
 			ContentHash: result.ContentHash,
 			Changed:     result.Status == "changed",
+			Triggered:        triggered,
 			Error:       result.Error,
+			Timing:           dbTiming(result.Timing),
+			Ping:             newPingStats(result.Ping),
+			Steps:            newStepOutputs(result.Steps),
+			FailedAssertions: result.FailedAssertions,
+			NewItems:         result.NewItems,
+			Sitemap:          result.Sitemap,
+			Value:            result.Value,
+			HeaderChanges:    result.HeaderChanges,
+			Security:         result.Security,
 		}
 
 		if result.SSLExpiry != nil {
@@ -126,77 +150,209 @@ func runCheck(cmd *cobra.Command, args []string) {
 			out.SSLDaysLeft = &days
 		}
 
-		outputs = append(outputs, out)
-
-		// Evaluate trigger rule and send notifications
-		if result.Status == "down" || result.Status == "changed" || result.Status == "error" {
-			shouldNotify := true
-			if t.TriggerRule != "" {
-				triggered, _ := trigger.Evaluate(t.TriggerRule, result.Content)
-				shouldNotify = triggered
-				out.Triggered = &triggered
-			}
-			if shouldNotify {
-				sendNotifications(t.Name, t.URL, result.Status, result.Error)
-			}
-		}
+		outputs[index[t.ID]] = out
 
 		if !jsonOutput {
 			// Clear the "checking" line
 			fmt.Printf("\r\033[K")
+			printCheckLine(&t, result)
+			if !quiet && remaining > 0 {
+				fmt.Printf("  ⟳ Checking %d target(s)...\r", remaining)
+			}
+		}
+	})
 
-			icon := statusIcon(result.Status)
-			statusText := result.Status
-			nameText := t.Name
-			urlText := fmt.Sprintf("(%s)", t.URL)
-			respText := fmt.Sprintf("[%dms]", result.ResponseTime.Milliseconds())
+	if jsonOutput {
+		printJSON(outputs)
+	}
+}
 
-			if !noColor {
-				switch result.Status {
-				case "up", "unchanged":
-					icon = colorGreen(icon)
-					statusText = colorGreen(statusText)
-				case "changed":
-					icon = colorYellow(icon)
-					statusText = colorYellow(statusText)
-				case "down", "error":
-					icon = colorRed(icon)
-					statusText = colorRed(statusText)
-				}
-				nameText = colorBold(t.Name)
-				urlText = colorCyan(fmt.Sprintf("(%s)", t.URL))
-			}
+// printCheckLine prints a one-line human-readable summary of a check result.
+func printCheckLine(t *db.Target, result *checker.Result) {
+	icon := statusIcon(result.Status)
+	statusText := result.Status
+	nameText := t.Name
+	urlText := fmt.Sprintf("(%s)", t.URL)
+	respText := fmt.Sprintf("[%dms]", result.ResponseTime.Milliseconds())
+
+	if !noColor {
+		switch result.Status {
+		case "up", "unchanged":
+			icon = colorGreen(icon)
+			statusText = colorGreen(statusText)
+		case "changed", "degraded":
+			icon = colorYellow(icon)
+			statusText = colorYellow(statusText)
+		case "down", "error":
+			icon = colorRed(icon)
+			statusText = colorRed(statusText)
+		}
+		nameText = colorBold(t.Name)
+		urlText = colorCyan(fmt.Sprintf("(%s)", t.URL))
+	}
 
-			fmt.Printf("%s %s %s — %s %s",
-				icon, nameText, urlText, statusText, respText)
-			if result.Error != "" {
-				errText := result.Error
-				if !noColor {
-					errText = colorRed(errText)
+	fmt.Printf("%s %s %s — %s %s",
+		icon, nameText, urlText, statusText, respText)
+	if result.Value != nil && result.Security == nil {
+		fmt.Printf(" = %s", checker.FormatValue(*result.Value))
+	}
+	if result.Error != "" {
+		errText := result.Error
+		if !noColor {
+			errText = colorRed(errText)
+		}
+		fmt.Printf(" (%s)", errText)
+	}
+	if result.SSLExpiry != nil {
+		days := int(time.Until(*result.SSLExpiry).Hours() / 24)
+		warnDays := config.Get().SSLWarnDays()
+		if days < warnDays {
+			sslText := fmt.Sprintf("[SSL: %dd]", days)
+			if !noColor {
+				if days < warnDays/2 {
+					sslText = colorRed(sslText)
+				} else {
+					sslText = colorYellow(sslText)
 				}
-				fmt.Printf(" (%s)", errText)
 			}
-			if result.SSLExpiry != nil {
-				days := int(time.Until(*result.SSLExpiry).Hours() / 24)
-				warnDays := config.Get().SSLWarnDays()
-				if days < warnDays {
-					sslText := fmt.Sprintf("[SSL: %dd]", days)
-					if !noColor {
-						if days < warnDays/2 {
-							sslText = colorRed(sslText)
-						} else {
-							sslText = colorYellow(sslText)
-						}
-					}
-					fmt.Printf(" %s", sslText)
-				}
+			fmt.Printf(" %s", sslText)
+		}
+	}
+	fmt.Println()
+	// Show transaction steps when one failed, or always with --verbose
+	if len(result.Steps) > 0 && (verbose || result.Status == "down") {
+		printSteps(result.Steps)
+	}
+	for _, it := range result.NewItems {
+		fmt.Printf("    + %s\n", checker.FormatFeedItem(it))
+	}
+	if result.Sitemap != nil {
+		fmt.Printf("    %s (upp diff %q)\n", result.Sitemap.Summary(), t.Name)
+	}
+	for _, c := range result.HeaderChanges {
+		fmt.Printf("    %s\n", c)
+	}
+	if result.Security != nil {
+		fmt.Printf("    %s\n", result.Security.Summary())
+		if verbose {
+			for _, f := range result.Security.Findings {
+				fmt.Printf("      %s\n", f)
 			}
-			fmt.Println()
 		}
 	}
+}
 
-	if jsonOutput {
-		printJSON(outputs)
+// dbTiming converts checker phase timings to the millisecond form stored
+// with check results.
+func dbTiming(t *checker.Timing) *db.Timing {
+	if t == nil {
+		return nil
+	}
+	return &db.Timing{
+		DNS:      t.DNS.Milliseconds(),
+		Connect:  t.Connect.Milliseconds(),
+		TLS:      t.TLS.Milliseconds(),
+		TTFB:     t.TTFB.Milliseconds(),
+		Transfer: t.Transfer.Milliseconds(),
+	}
+}
+
+// formatTiming renders a phase breakdown as "dns 3ms  connect 12ms ...".
+func formatTiming(t *db.Timing) string {
+	return fmt.Sprintf("dns %dms  connect %dms  tls %dms  ttfb %dms  transfer %dms",
+		t.DNS, t.Connect, t.TLS, t.TTFB, t.Transfer)
+}
+
+// pingStats is the JSON form of ICMP round-trip statistics.
+type pingStats struct {
+	Sent        int     `json:"sent"`
+	Received    int     `json:"received"`
+	LossPercent float64 `json:"loss_percent"`
+	MinMs       float64 `json:"min_ms"`
+	AvgMs       float64 `json:"avg_ms"`
+	MaxMs       float64 `json:"max_ms"`
+	JitterMs    float64 `json:"jitter_ms"`
+}
+
+func newPingStats(p *checker.PingStats) *pingStats {
+	if p == nil {
+		return nil
+	}
+	ms := func(d time.Duration) float64 { return float64(d.Microseconds()) / 1000 }
+	return &pingStats{
+		Sent:        p.Sent,
+		Received:    p.Received,
+		LossPercent: p.Loss,
+		MinMs:       ms(p.Min),
+		AvgMs:       ms(p.Avg),
+		MaxMs:       ms(p.Max),
+		JitterMs:    ms(p.Jitter),
+	}
+}
+
+// formatPingStats renders ICMP statistics like the summary line of ping(8).
+func formatPingStats(p *pingStats) string {
+	s := fmt.Sprintf("%d sent, %d received, %.0f%% loss", p.Sent, p.Received, p.LossPercent)
+	if p.Received > 0 {
+		s += fmt.Sprintf(", rtt min/avg/max %.2f/%.2f/%.2fms, jitter %.2fms", p.MinMs, p.AvgMs, p.MaxMs, p.JitterMs)
+	}
+	return s
+}
+
+// stepOutput is the JSON form of a transaction step result.
+type stepOutput struct {
+	Name       string     `json:"name,omitempty"`
+	Method     string     `json:"method"`
+	URL        string     `json:"url"`
+	StatusCode int        `json:"status_code,omitempty"`
+	ResponseMs int64      `json:"response_time_ms"`
+	Timing     *db.Timing `json:"timing,omitempty"`
+	Error      string     `json:"error,omitempty"`
+}
+
+func newStepOutputs(steps []checker.StepResult) []stepOutput {
+	var out []stepOutput
+	for _, s := range steps {
+		out = append(out, stepOutput{
+			Name:       s.Name,
+			Method:     s.Method,
+			URL:        s.URL,
+			StatusCode: s.StatusCode,
+			ResponseMs: s.Duration.Milliseconds(),
+			Timing:     dbTiming(s.Timing),
+			Error:      s.Error,
+		})
+	}
+	return out
+}
+
+// printSteps prints one indented line per transaction step.
+func printSteps(steps []checker.StepResult) {
+	for i, s := range steps {
+		icon := "✓"
+		if s.Error != "" {
+			icon = "✗"
+		}
+		if !noColor {
+			if s.Error != "" {
+				icon = colorRed(icon)
+			} else {
+				icon = colorGreen(icon)
+			}
+		}
+		label := s.Name
+		if label == "" {
+			label = fmt.Sprintf("step %d", i+1)
+		}
+		fmt.Printf("    %s %d. %s — %s %s", icon, i+1, label, s.Method, s.URL)
+		if s.StatusCode != 0 {
+			fmt.Printf(" → %d", s.StatusCode)
+		}
+		fmt.Printf(" [%dms]", s.Duration.Milliseconds())
+		if s.Error != "" {
+			fmt.Printf(" (%s)", s.Error)
+		}
+		fmt.Println()
 	}
 }
 
@@ -206,6 +362,8 @@ func statusIcon(status string) string {
 		return "✓"
 	case "changed":
 		return "△"
+	case "degraded":
+		return "⚠"
 	case "down":
 		return "✗"
 	default:
@@ -213,29 +371,221 @@ func statusIcon(status string) string {
 	}
 }
 
-func sendNotifications(target, url, status, errMsg string) {
-	configs, err := db.ListNotifyConfigs()
-	if err != nil || len(configs) == 0 {
-		return
+// recordResult persists a check result and its snapshot, advances the target's
+// alert state and sends notifications for any state transitions. It returns the
+// trigger rule outcome when a rule was evaluated. Canceled checks are not recorded.
+func recordResult(t *db.Target, result *checker.Result) *bool {
+	if result.Canceled {
+		return nil
+	}
+	cr := &db.CheckResult{
+		TargetID:     t.ID,
+		Status:       result.Status,
+		StatusCode:   result.StatusCode,
+		ResponseTime: result.ResponseTime.Milliseconds(),
+		ContentHash:  result.ContentHash,
+		Error:        result.Error,
+		Body:         result.PingBody,
+		Timing:       dbTiming(result.Timing),
+	}
+	db.SaveCheckResult(cr)
+
+	// Append the tracked value to the time series, keeping the previous
+	// one for drop and rise trigger rules
+	var prevValue *float64
+	if result.Value != nil {
+		if prev, err := db.GetValues(t.ID, time.Time{}, 1); err == nil && len(prev) > 0 {
+			prevValue = &prev[0].Value
+		}
+		db.SaveValue(t.ID, *result.Value)
+	}
+
+	// Remember feed items so later checks only report new ones
+	if len(result.FeedItems) > 0 {
+		db.SaveFeedItems(t.ID, result.FeedItems, result.Status == "up")
+	}
+	if t.Type == "feed" && result.Status != "error" && result.ContentHash != "" {
+		db.SetFeedHash(t.ID, result.ContentHash)
+	}
+
+	// Save snapshot if content or watched headers available
+	if (result.Content != "" || result.Headers != "") && result.ContentHash != "" {
+		snaps, _ := db.GetLatestSnapshots(t.ID, 1)
+		if len(snaps) == 0 || snaps[0].Hash != result.ContentHash || snaps[0].HeaderHash != result.HeaderHash {
+			db.SaveSnapshot(t.ID, result.Content, result.RawContent, result.ContentHash, result.Headers, result.HeaderHash)
+		}
+	}
+
+	state, err := db.GetAlertState(t.ID)
+	if err != nil {
+		return nil
+	}
+	cfg := config.Get()
+	now := time.Now()
+	since, previous := state.Since, state.State
+	before := *state
+	events := alert.Evaluate(state, result.Status, now, alert.Policy{
+		FailureThreshold: cfg.FailureThreshold(),
+		ReminderInterval: cfg.ReminderInterval(),
+	})
+	if result.SSLExpiry != nil && alert.EvaluateSSL(state, *result.SSLExpiry, now, cfg.SSLWarnDays()) {
+		events = append(events, alert.EventSSLExpiring)
+	}
+
+	var triggered *bool
+	for _, ev := range events {
+		items := result.NewItems
+		// Trigger rules filter content-driven alerts; recoveries and
+		// reminders always go out so an open alert is never left dangling.
+		// For feeds the rule is matched against the titles of new items.
+		if t.TriggerRule != "" && ev == alert.EventChanged && len(items) > 0 {
+			items = matchingFeedItems(t.TriggerRule, items)
+			ok := len(items) > 0
+			triggered = &ok
+			if !ok {
+				continue
+			}
+		} else if t.TriggerRule != "" && trigger.IsValueRule(trigger.RuleType(t.TriggerRule)) {
+			// Value rules filter changes of the tracked value; a target
+			// going down is always reported
+			if ev == alert.EventChanged {
+				ok := false
+				if result.Value != nil {
+					ok, _ = trigger.EvaluateValue(t.TriggerRule, prevValue, *result.Value)
+				}
+				triggered = &ok
+				if !ok {
+					continue
+				}
+			}
+		} else if t.TriggerRule != "" && (ev == alert.EventDown || ev == alert.EventChanged) {
+			ok, _ := trigger.Evaluate(t.TriggerRule, result.Content)
+			triggered = &ok
+			if !ok {
+				// An outage that was never notified must not be
+				// recovered from either: stay in the previous state, so
+				// the rule is matched again on the next failed check
+				if ev == alert.EventDown {
+					state.State, state.Since, state.LastNotified = before.State, before.Since, before.LastNotified
+				}
+				continue
+			}
+		}
+		if ev != alert.EventChanged {
+			items = nil
+		}
+		sendNotifications(t, ev, result, previous, now.Sub(since), items)
 	}
+	db.SaveAlertState(state)
+	return triggered
+}
 
-	msg := fmt.Sprintf("[upp] %s (%s) is %s", target, url, status)
-	if errMsg != "" {
-		msg += ": " + errMsg
+// maxExcerptLines bounds the diff excerpt sent with change notifications.
+const maxExcerptLines = 20
+
+// maxExcerptCells bounds the size of the line diff computed for an excerpt
+// (old lines times new lines), so huge pages don't stall notifications.
+const maxExcerptCells = 4000000
+
+// changeExcerpt returns the changed lines between the previous snapshot and
+// the result's content, up to maxExcerptLines of them, and a summary of how
+// many lines were added and removed.
+func changeExcerpt(targetID int64, result *checker.Result) (string, string) {
+	snaps, err := db.GetLatestSnapshots(targetID, 2)
+	if err != nil || len(snaps) < 2 || snaps[0].Hash != result.ContentHash {
+		return "", ""
 	}
+	old, cur := snaps[1].Content, result.Content
+	if (strings.Count(old, "\n")+1)*(strings.Count(cur, "\n")+1) > maxExcerptCells {
+		return "", ""
+	}
+	d := diff.Diff(old, cur)
+	var sb strings.Builder
+	shown := 0
+	for _, c := range d.Changes {
+		if c.Type == "context" {
+			continue
+		}
+		if shown == maxExcerptLines {
+			fmt.Fprintf(&sb, "… %d more changed lines\n", d.Added+d.Removed-shown)
+			break
+		}
+		prefix := "+ "
+		if c.Type == "removed" {
+			prefix = "- "
+		}
+		sb.WriteString(prefix + c.Line + "\n")
+		shown++
+	}
+	if shown == 0 {
+		return "", ""
+	}
+	return sb.String(), fmt.Sprintf("%d added, %d removed", d.Added, d.Removed)
+}
 
-	event := notify.Event{
-		Target:  target,
-		URL:     url,
-		Status:  status,
-		Error:   errMsg,
-		Time:    time.Now().UTC().Format(time.RFC3339),
-		Message: msg,
+// matchingFeedItems returns the feed items whose title satisfies a trigger
+// rule.
+func matchingFeedItems(rule string, items []db.FeedItem) []db.FeedItem {
+	var matched []db.FeedItem
+	for _, it := range items {
+		if ok, _ := trigger.Evaluate(rule, it.Title); ok {
+			matched = append(matched, it)
+		}
+	}
+	return matched
+}
+
+func sendNotifications(t *db.Target, event string, result *checker.Result, previous string, duration time.Duration, items []db.FeedItem) {
+	status := result.Status
+	channels, err := routeChannels(t, routeEvent(event, status))
+	if err != nil || len(channels) == 0 {
+		return
 	}
 
-	for _, c := range configs {
-		if c.Enabled {
-			notify.Send(c.Type, c.Config, event)
+	// The message itself is rendered by each channel's template
+	tags, _ := db.GetTags(t.ID)
+	ev := notify.Event{
+		TargetID:       t.ID,
+		Target:         t.Name,
+		URL:            t.URL,
+		Tags:           tags,
+		Event:          event,
+		Status:         status,
+		PreviousStatus: previous,
+		StatusCode:     result.StatusCode,
+		ResponseTime:   result.ResponseTime.Milliseconds(),
+		Time:           time.Now().UTC().Format(time.RFC3339),
+	}
+	if event != alert.EventRecovery && event != alert.EventSSLExpiring {
+		ev.Error = result.Error
+	}
+	if event == alert.EventRecovery || event == alert.EventReminder {
+		ev.Downtime = duration.Round(time.Second).String()
+	}
+	if result.SSLExpiry != nil {
+		days := int(time.Until(*result.SSLExpiry).Hours() / 24)
+		ev.SSLDays = &days
+		ev.SSLExpiry = result.SSLExpiry.Format("2006-01-02")
+	}
+	if event == alert.EventChanged {
+		if result.Sitemap != nil {
+			ev.Summary = result.Sitemap.Summary()
+		}
+		if result.Security != nil {
+			ev.Summary = result.Security.Summary()
+		}
+		for _, c := range result.HeaderChanges {
+			ev.Headers = append(ev.Headers, c.String())
+		}
+		if len(items) == 0 {
+			ev.Diff, ev.DiffSummary = changeExcerpt(t.ID, result)
 		}
 	}
+	for _, it := range items {
+		ev.Items = append(ev.Items, notify.Item{Title: it.Title, Link: it.Link, Published: it.Published})
+	}
+
+	for _, c := range channels {
+		deliver(c, t.ID, ev)
+	}
 }
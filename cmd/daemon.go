@@ -2,30 +2,55 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/naru-bot/upp/internal/checker"
+	"github.com/naru-bot/upp/internal/config"
 	"github.com/naru-bot/upp/internal/db"
-	"github.com/naru-bot/upp/internal/trigger"
+	"github.com/naru-bot/upp/internal/engine"
+	"github.com/naru-bot/upp/internal/schedule"
 	"github.com/spf13/cobra"
 )
 
 func init() {
-	rootCmd.AddCommand(&cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "daemon",
 		Short: "Run as background daemon with scheduled checks",
 		Long: `Start upp as a long-running process that checks all targets
 on their configured intervals.
 
+Each target's next run time is stored in the database, so restarting the
+daemon resumes the schedule instead of re-checking everything at once.
+Targets sharing an interval are spread across it with a fixed per-target
+offset.
+
+The daemon also receives pings for heartbeat targets over HTTP, on
+127.0.0.1:8425 unless --listen or daemon.listen in config.yml says
+otherwise:
+
+  POST /ping/<token>           job succeeded
+  POST /ping/<token>/start     job started (the next ping records its duration)
+  POST /ping/<token>/fail      job failed
+  POST /ping/<token>/<code>    job exited with code (0 = success)
+
+Notifications that fail to send are retried with exponential backoff
+while the daemon runs (see 'upp notify log').
+
 Examples:
   upp daemon
   upp daemon &           # run in background
-  nohup upp daemon &     # survive terminal close`,
+  nohup upp daemon &     # survive terminal close
+  upp daemon --listen 0.0.0.0:8425`,
 		Run: runDaemon,
-	})
+	}
+	cmd.Flags().String("listen", "", "Address to serve heartbeat pings on (default 127.0.0.1:8425)")
+	rootCmd.AddCommand(cmd)
 }
 
 func runDaemon(cmd *cobra.Command, args []string) {
@@ -35,15 +60,47 @@ func runDaemon(cmd *cobra.Command, args []string) {
 	sig := make(chan os.Signal, 1)
 	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
 
-	ticker := time.NewTicker(10 * time.Second)
+	// A one-second tick keeps checks within a second of their scheduled time;
+	// the schedule itself lives in the database so restarts resume it.
+	ticker := time.NewTicker(1 * time.Second)
 	defer ticker.Stop()
 
-	lastCheck := make(map[int64]time.Time)
+	cfg := config.Get()
+	eng := engine.New(cfg.MaxConcurrency(), cfg.ExpensiveConcurrency())
+	resumed := make(map[int64]bool)
+
+	listen, _ := cmd.Flags().GetString("listen")
+	if listen == "" {
+		listen = cfg.DaemonListen()
+	}
+	srv := &http.Server{Addr: listen, Handler: pingHandler(eng), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			fmt.Fprintf(os.Stderr, "Heartbeat endpoint disabled: %v\n", err)
+		}
+	}()
+	defer srv.Close()
+	fmt.Printf("Listening for heartbeat pings on http://%s/ping/<token>\n", listen)
+
+	// Failed notifications are retried in the background, so a slow
+	// channel never delays scheduled checks
+	stopRetries := make(chan struct{})
+	defer close(stopRetries)
+	go retryNotifications(stopRetries)
 
 	for {
 		select {
 		case <-sig:
-			fmt.Println("\n🐕 Upp daemon stopped")
+			fmt.Println("\n🐕 Upp daemon stopping, waiting for in-flight checks (Ctrl+C again to abort)...")
+			// A second signal aborts running checks immediately
+			go func() {
+				<-sig
+				eng.Abort()
+			}()
+			if !eng.Shutdown(cfg.ShutdownGrace()) {
+				fmt.Println("Aborted checks still running")
+			}
+			fmt.Println("🐕 Upp daemon stopped")
 			return
 		case <-ticker.C:
 			targets, err := db.ListTargets()
@@ -57,46 +114,172 @@ func runDaemon(cmd *cobra.Command, args []string) {
 					continue
 				}
 
-				last, ok := lastCheck[t.ID]
-				if ok && now.Sub(last) < time.Duration(t.Interval)*time.Second {
-					continue
+				interval := time.Duration(t.Interval) * time.Second
+				if interval <= 0 {
+					interval = time.Duration(cfg.Defaults.Interval) * time.Second
 				}
 
-				result := checker.Check(&t)
-				lastCheck[t.ID] = now
-
-				cr := &db.CheckResult{
-					TargetID:     t.ID,
-					Status:       result.Status,
-					StatusCode:   result.StatusCode,
-					ResponseTime: result.ResponseTime.Milliseconds(),
-					ContentHash:  result.ContentHash,
-					Error:        result.Error,
+				if t.Type == "heartbeat" {
+					scheduleHeartbeat(eng, t, interval, now, resumed)
+					continue
 				}
-				db.SaveCheckResult(cr)
 
-				if result.Content != "" && result.ContentHash != "" {
-					snaps, _ := db.GetLatestSnapshots(t.ID, 1)
-					if len(snaps) == 0 || snaps[0].Hash != result.ContentHash {
-						db.SaveSnapshot(t.ID, result.Content, result.ContentHash)
-					}
+				// On first sight, pick up the persisted schedule (or create one);
+				// also reschedule new targets and ones whose interval was shortened
+				if !resumed[t.ID] || t.NextRunAt == nil || t.NextRunAt.Sub(now) > interval {
+					due := schedule.Resume(t.ID, interval, t.NextRunAt, now)
+					db.SetNextRun(t.ID, due)
+					t.NextRunAt = &due
+					resumed[t.ID] = true
+				}
+				if now.Before(*t.NextRunAt) {
+					continue
 				}
 
-				icon := statusIcon(result.Status)
-				fmt.Printf("[%s] %s %s — %s [%dms]\n",
-					now.Format("15:04:05"), icon, t.Name, result.Status, result.ResponseTime.Milliseconds())
-
-				if result.Status == "down" || result.Status == "changed" || result.Status == "error" {
-					shouldNotify := true
-					if t.TriggerRule != "" {
-						triggered, _ := trigger.Evaluate(t.TriggerRule, result.Content)
-						shouldNotify = triggered
-					}
-					if shouldNotify {
-						sendNotifications(t.Name, t.URL, result.Status, result.Error)
-					}
+				// Skip targets whose previous check is still running
+				if !eng.Submit(t, daemonCheckDone) {
+					continue
 				}
+				db.SetNextRun(t.ID, schedule.Next(interval, *t.NextRunAt, now))
 			}
 		}
 	}
 }
+
+// retryInterval is how often the daemon looks for failed notifications due
+// for a retry.
+const retryInterval = 10 * time.Second
+
+// retryNotifications resends failed notifications as their backoff expires,
+// until stop is closed.
+func retryNotifications(stop <-chan struct{}) {
+	ticker := time.NewTicker(retryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			if deliveries, err := db.ListDueDeliveries(now); err == nil {
+				retryDeliveries(deliveries)
+			}
+		}
+	}
+}
+
+// scheduleHeartbeat checks a heartbeat target once its ping is overdue, and
+// again every interval while it stays overdue. Pings push the due time
+// back, so on time targets are never checked.
+func scheduleHeartbeat(eng *engine.Engine, t db.Target, interval time.Duration, now time.Time, resumed map[int64]bool) {
+	if !resumed[t.ID] || t.NextRunAt == nil {
+		due, err := checker.HeartbeatDue(&t)
+		if err != nil {
+			return
+		}
+		db.SetNextRun(t.ID, due)
+		t.NextRunAt = &due
+		resumed[t.ID] = true
+	}
+	if now.Before(*t.NextRunAt) {
+		return
+	}
+	if !eng.Submit(t, daemonCheckDone) {
+		return
+	}
+	db.SetNextRun(t.ID, now.Add(interval))
+}
+
+// maxPingBody bounds how much of a ping's body is stored.
+const maxPingBody = 10 * 1024
+
+// pingHandler records heartbeat pings sent to /ping/<token>[/<kind>].
+// Pings of paused targets are acknowledged but ignored. Recording goes
+// through the engine, so a ping never interleaves with a check of the same
+// target.
+func pingHandler(eng *engine.Engine) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handlePing(eng, w, r)
+	})
+}
+
+func handlePing(eng *engine.Engine, w http.ResponseWriter, r *http.Request) {
+	path, ok := strings.CutPrefix(r.URL.Path, "/ping/")
+	if !ok || (r.Method != http.MethodGet && r.Method != http.MethodPost && r.Method != http.MethodHead) {
+		http.NotFound(w, r)
+		return
+	}
+	token, kind, _ := strings.Cut(path, "/")
+	if kind == "" {
+		kind = checker.PingSuccess
+	}
+
+	t := heartbeatTarget(token)
+	if t == nil {
+		http.Error(w, "unknown heartbeat token", http.StatusNotFound)
+		return
+	}
+	if t.Paused {
+		fmt.Fprintln(w, "OK (paused)")
+		return
+	}
+	body, _ := io.ReadAll(io.LimitReader(r.Body, maxPingBody))
+
+	var status int
+	var errMsg string
+	ran := eng.Exclusive(t.ID, func() {
+		now := time.Now()
+		if kind == checker.PingStart {
+			if err := db.StartHeartbeat(t.ID, now); err != nil {
+				status, errMsg = http.StatusInternalServerError, err.Error()
+			}
+			return
+		}
+		result, err := checker.PingResult(t, kind, string(body), now)
+		if err != nil {
+			status, errMsg = http.StatusBadRequest, err.Error()
+			return
+		}
+		recordResult(t, result)
+		if due, err := checker.HeartbeatDue(t); err == nil {
+			db.SetNextRun(t.ID, due)
+		}
+		fmt.Printf("[%s] %s %s — ping: %s\n", now.Format("15:04:05"), statusIcon(result.Status), t.Name, result.Status)
+	})
+	if !ran {
+		http.Error(w, "daemon is shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	if errMsg != "" {
+		http.Error(w, errMsg, status)
+		return
+	}
+	fmt.Fprintln(w, "OK")
+}
+
+// heartbeatTarget finds the heartbeat target pinged with token.
+func heartbeatTarget(token string) *db.Target {
+	if token == "" {
+		return nil
+	}
+	targets, err := db.ListTargets()
+	if err != nil {
+		return nil
+	}
+	for i := range targets {
+		if targets[i].Type == "heartbeat" && checker.HeartbeatToken(&targets[i]) == token {
+			return &targets[i]
+		}
+	}
+	return nil
+}
+
+func daemonCheckDone(t db.Target, result *checker.Result) {
+	if result.Canceled {
+		return
+	}
+	recordResult(&t, result)
+
+	icon := statusIcon(result.Status)
+	fmt.Printf("[%s] %s %s — %s [%dms]\n",
+		time.Now().Format("15:04:05"), icon, t.Name, result.Status, result.ResponseTime.Milliseconds())
+}
@@ -1,31 +1,45 @@
 package cmd
 
 import (
+	"crypto/sha256"
 	"fmt"
 	"time"
 
+	"github.com/naru-bot/upp/internal/checker"
 	"github.com/naru-bot/upp/internal/db"
 	"github.com/spf13/cobra"
 )
 
 func init() {
-	rootCmd.AddCommand(&cobra.Command{
+	cmd := &cobra.Command{
 		Use:   "data <name|url|id>",
 		Short: "Show latest stored snapshot content for a target",
 		Long: `Show the latest stored snapshot content for a target.
 
+HTML pages monitored without a selector are stored as readable text (see
+--render on 'upp add'); --raw shows the response body as it was received.
+
+With --show-normalized, show the content after the target's and the global
+normalization rules are applied — exactly what is hashed for change detection.
+
 Examples:
   upp data "My Site"
   upp data https://example.com
-  upp data 1`,
+  upp data 1
+  upp data "News" --raw
+  upp data "News" --show-normalized`,
 		Args: requireArgs(1),
 		Run:  runData,
-	})
+	}
+	cmd.Flags().Bool("raw", false, "Show the raw response body instead of the rendered text")
+	cmd.Flags().Bool("show-normalized", false, "Show the normalized content used for change detection")
+	rootCmd.AddCommand(cmd)
 }
 
 type dataOutput struct {
-	Target   db.Target   `json:"target"`
-	Snapshot db.Snapshot `json:"snapshot"`
+	Target     db.Target   `json:"target"`
+	Snapshot   db.Snapshot `json:"snapshot"`
+	Normalized *string     `json:"normalized,omitempty"`
 }
 
 func runData(cmd *cobra.Command, args []string) {
@@ -48,16 +62,40 @@ func runData(cmd *cobra.Command, args []string) {
 	}
 
 	snap := snaps[0]
+	content := snap.Content
+	raw, _ := cmd.Flags().GetBool("raw")
+	showNormalized, _ := cmd.Flags().GetBool("show-normalized")
+	if raw && showNormalized {
+		exitError("--raw and --show-normalized can't be combined")
+	}
+	if raw && snap.Raw != "" {
+		content = snap.Raw
+	}
+	if showNormalized {
+		if content, err = checker.NormalizedContent(t, snap.Content); err != nil {
+			exitError(err.Error())
+		}
+	}
 	if jsonOutput {
-		printJSON(dataOutput{Target: *t, Snapshot: snap})
+		out := dataOutput{Target: *t, Snapshot: snap}
+		if showNormalized {
+			out.Normalized = &content
+		}
+		printJSON(out)
 		return
 	}
 
 	fmt.Printf("Target: %s (id %d)\n", t.Name, t.ID)
 	fmt.Printf("URL: %s\n", t.URL)
-	fmt.Printf("Snapshot: %s\n\n", snap.CreatedAt.Format(time.RFC3339))
-	fmt.Print(snap.Content)
-	if len(snap.Content) > 0 && snap.Content[len(snap.Content)-1] != '\n' {
+	fmt.Printf("Snapshot: %s\n", snap.CreatedAt.Format(time.RFC3339))
+	if showNormalized && fmt.Sprintf("%x", sha256.Sum256([]byte(content))) != snap.Hash {
+		// Rules edited since the snapshot was taken; the next check will
+		// report a change even if the page didn't.
+		fmt.Println("Note: normalization rules changed since this snapshot; its stored hash differs")
+	}
+	fmt.Println()
+	fmt.Print(content)
+	if len(content) > 0 && content[len(content)-1] != '\n' {
 		fmt.Print("\n")
 	}
 }
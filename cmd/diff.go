@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/naru-bot/upp/internal/checker"
 	"github.com/naru-bot/upp/internal/db"
 	"github.com/naru-bot/upp/internal/diff"
 	"github.com/spf13/cobra"
@@ -14,7 +15,11 @@ func init() {
 		Short: "Show content changes between snapshots",
 		Long: `Show what changed in the monitored page content.
 
-Compares the two most recent snapshots and displays a unified diff.
+Compares the two most recent snapshots and displays a unified diff. For
+feed targets, lists the entries the latest change reported as new; for
+sitemap targets, lists the URLs added, removed and with a new lastmod.
+Changes to watched response headers (--watch-headers) are listed after
+the content diff.
 
 Examples:
   upp diff "My Site"
@@ -35,6 +40,9 @@ type diffOutput struct {
 	Changes    []diff.Change `json:"changes,omitempty"`
 	OldTime    string        `json:"old_snapshot_time,omitempty"`
 	NewTime    string        `json:"new_snapshot_time,omitempty"`
+	NewItems      []db.FeedItem          `json:"new_items,omitempty"`
+	Sitemap       *checker.SitemapDiff   `json:"sitemap,omitempty"`
+	HeaderChanges []checker.HeaderChange `json:"header_changes,omitempty"`
 }
 
 func runDiff(cmd *cobra.Command, args []string) {
@@ -43,6 +51,11 @@ func runDiff(cmd *cobra.Command, args []string) {
 		exitError(err.Error())
 	}
 
+	if t.Type == "feed" {
+		diffFeed(t)
+		return
+	}
+
 	snaps, err := db.GetLatestSnapshots(t.ID, 2)
 	if err != nil {
 		exitError(err.Error())
@@ -63,24 +76,146 @@ func runDiff(cmd *cobra.Command, args []string) {
 	}
 
 	// snaps[0] is newest, snaps[1] is older
+	if t.Type == "sitemap" {
+		diffSitemap(t, snaps[1], snaps[0])
+		return
+	}
 	d := diff.Diff(snaps[1].Content, snaps[0].Content)
+	var headerChanges []checker.HeaderChange
+	if snaps[1].HeaderHash != "" && snaps[0].HeaderHash != "" {
+		headerChanges = checker.DiffHeaders(snaps[1].Headers, snaps[0].Headers)
+	}
+	summary := d.Summary
+	if len(headerChanges) > 0 {
+		summary += fmt.Sprintf(", %d header(s) changed", len(headerChanges))
+	}
 
 	if jsonOutput {
 		printJSON(diffOutput{
 			Target:     t.Name,
 			URL:        t.URL,
-			HasChanges: d.HasChanges,
-			Summary:    d.Summary,
+			HasChanges:    d.HasChanges || len(headerChanges) > 0,
+			Summary:       summary,
 			Added:      d.Added,
 			Removed:    d.Removed,
 			Changes:    d.Changes,
 			OldTime:    snaps[1].CreatedAt.String(),
 			NewTime:    snaps[0].CreatedAt.String(),
+			HeaderChanges: headerChanges,
 		})
 		return
 	}
 
 	fmt.Printf("Changes for: %s (%s)\n", t.Name, t.URL)
 	fmt.Printf("Old: %s\nNew: %s\n\n", snaps[1].CreatedAt.Format("2006-01-02 15:04:05"), snaps[0].CreatedAt.Format("2006-01-02 15:04:05"))
-	fmt.Print(diff.FormatUnified(d, "previous", "current"))
+	if d.HasChanges || len(headerChanges) == 0 {
+		fmt.Print(diff.FormatUnified(d, "previous", "current"))
+	}
+	if len(headerChanges) > 0 {
+		if d.HasChanges {
+			fmt.Println()
+		}
+		fmt.Println("Headers:")
+		for _, c := range headerChanges {
+			line := c.String()
+			if !noColor {
+				switch {
+				case c.Old == "":
+					line = colorGreen(line)
+				case c.New == "":
+					line = colorRed(line)
+				default:
+					line = colorYellow(line)
+				}
+			}
+			fmt.Println(line)
+		}
+	}
+}
+
+// diffFeed lists the entries a feed target most recently reported as new.
+func diffFeed(t *db.Target) {
+	items, seenAt, err := db.GetNewFeedItems(t.ID)
+	if err != nil {
+		exitError(err.Error())
+	}
+
+	summary := "No new entries since the feed was first checked"
+	if len(items) > 0 {
+		summary = fmt.Sprintf("%d new entries", len(items))
+	}
+	if jsonOutput {
+		out := diffOutput{
+			Target:     t.Name,
+			URL:        t.URL,
+			HasChanges: len(items) > 0,
+			Summary:    summary,
+			Added:      len(items),
+			NewItems:   items,
+		}
+		if len(items) > 0 {
+			out.NewTime = seenAt.String()
+		}
+		printJSON(out)
+		return
+	}
+
+	fmt.Printf("Changes for: %s (%s)\n", t.Name, t.URL)
+	if len(items) == 0 {
+		fmt.Println(summary + ".")
+		return
+	}
+	fmt.Printf("New entries seen %s:\n\n", seenAt.Local().Format("2006-01-02 15:04:05"))
+	for _, it := range items {
+		line := "+ " + checker.FormatFeedItem(it)
+		if !noColor {
+			line = colorGreen(line)
+		}
+		fmt.Println(line)
+	}
+}
+
+// diffSitemap lists the URLs added to, removed from and updated in a
+// sitemap target between two snapshots.
+func diffSitemap(t *db.Target, old, cur db.Snapshot) {
+	d := checker.DiffSitemaps(old.Content, cur.Content)
+	if jsonOutput {
+		printJSON(diffOutput{
+			Target:     t.Name,
+			URL:        t.URL,
+			HasChanges: d.HasChanges(),
+			Summary:    d.Summary(),
+			Added:      len(d.Added),
+			Removed:    len(d.Removed),
+			OldTime:    old.CreatedAt.String(),
+			NewTime:    cur.CreatedAt.String(),
+			Sitemap:    d,
+		})
+		return
+	}
+
+	fmt.Printf("Changes for: %s (%s)\n", t.Name, t.URL)
+	fmt.Printf("Old: %s\nNew: %s\n\n", old.CreatedAt.Format("2006-01-02 15:04:05"), cur.CreatedAt.Format("2006-01-02 15:04:05"))
+	fmt.Printf("%s\n\n", d.Summary())
+	for _, u := range d.Added {
+		line := "+ " + u.Loc
+		if !noColor {
+			line = colorGreen(line)
+		}
+		fmt.Println(line)
+	}
+	for _, u := range d.Removed {
+		line := "- " + u.Loc
+		if !noColor {
+			line = colorRed(line)
+		}
+		fmt.Println(line)
+	}
+	for _, u := range d.Updated {
+		line := fmt.Sprintf("~ %s (lastmod %s → %s)", u.Loc, u.OldLastMod, u.NewLastMod)
+		if !noColor {
+			line = colorYellow(line)
+		}
+		fmt.Println(line)
+	}
 }
@@ -5,7 +5,10 @@ import (
 	"os/exec"
 	"runtime"
 	"strings"
+	"time"
 
+	"github.com/naru-bot/upp/internal/checker"
+	"github.com/naru-bot/upp/internal/db"
 	"github.com/spf13/cobra"
 )
 
@@ -17,6 +20,7 @@ func init() {
 
 This command verifies that required tools are available for advanced features:
 - Headless browser (required for visual checks)
+- Client certificates and CA bundles referenced by targets
 
 Examples:
   upp doctor`,
@@ -57,6 +61,14 @@ func runDoctor(cmd *cobra.Command, args []string) {
 	}
 	checks = append(checks, browserCheck)
 
+	// Check that referenced client certificates and CA bundles parse
+	for _, c := range checkTLSFiles() {
+		if c.Status != "ok" {
+			issueCount++
+		}
+		checks = append(checks, c)
+	}
+
 	if jsonOutput {
 		printJSON(doctorOutput{
 			Checks:     checks,
@@ -71,6 +83,10 @@ func runDoctor(cmd *cobra.Command, args []string) {
 		
 		switch check.Status {
 		case "ok":
+			if check.Path == "" {
+				fmt.Printf("  %s %s %s\n", colorGreen("✅"), check.Name, check.Version)
+				break
+			}
 			fmt.Printf("  %s %s %s (%s)\n", colorGreen("✅"), check.Name, check.Version, check.Path)
 		case "missing":
 			fmt.Printf("  %s %s\n", colorRed("❌"), check.Message)
@@ -135,6 +151,55 @@ func checkHeadlessBrowser() doctorCheck {
 	}
 }
 
+// checkTLSFiles loads the client certificate and CA bundle of every target
+// that references them.
+func checkTLSFiles() []doctorCheck {
+	targets, err := db.ListTargets()
+	if err != nil {
+		return nil
+	}
+	var checks []doctorCheck
+	for _, t := range targets {
+		if t.ClientCert == "" && t.ClientKey == "" && t.CACert == "" {
+			continue
+		}
+		c := doctorCheck{
+			Name:        t.Name,
+			Description: fmt.Sprintf("TLS files (%s)", t.Name),
+		}
+		var paths []string
+		for _, ref := range []string{t.ClientCert, t.ClientKey, t.CACert} {
+			if ref != "" && !checker.IsInlinePEM(ref) {
+				paths = append(paths, ref)
+			}
+		}
+		c.Path = strings.Join(paths, ", ")
+
+		_, _, info, err := checker.LoadTLSFiles(t.ClientCert, t.ClientKey, t.CACert)
+		if err != nil {
+			c.Status = "error"
+			c.Message = fmt.Sprintf("%s: %v", t.Name, err)
+			checks = append(checks, c)
+			continue
+		}
+		c.Status = "ok"
+		var details []string
+		if cert := info.ClientCert; cert != nil {
+			details = append(details, fmt.Sprintf("client cert %s, expires %s", cert.Subject.CommonName, cert.NotAfter.Format("2006-01-02")))
+			if time.Now().After(cert.NotAfter) {
+				c.Status = "error"
+				c.Message = fmt.Sprintf("%s: client certificate expired on %s", t.Name, cert.NotAfter.Format("2006-01-02"))
+			}
+		}
+		if info.CACerts > 0 {
+			details = append(details, fmt.Sprintf("%d CA certificate%s", info.CACerts, pluralize(info.CACerts)))
+		}
+		c.Version = strings.Join(details, "; ")
+		checks = append(checks, c)
+	}
+	return checks
+}
+
 func getInstallInstructions(component string) string {
 	if component != "headless-browser" {
 		return ""
@@ -2,9 +2,13 @@ package cmd
 
 import (
 	"fmt"
+	"math"
 	"os"
+	"strings"
 	"text/tabwriter"
+	"time"
 
+	"github.com/naru-bot/upp/internal/checker"
 	"github.com/naru-bot/upp/internal/db"
 	"github.com/spf13/cobra"
 )
@@ -13,10 +17,22 @@ func init() {
 	cmd := &cobra.Command{
 		Use:   "history <name|url|id>",
 		Short: "Show check history for a target",
-		Args:  requireArgs(1),
-		Run:   runHistory,
+		Long: `Show recent check results for a target.
+
+With --value, show the series of values tracked by a numeric target
+(--numeric) instead, with the change from one value to the next.
+
+Examples:
+  upp history "My Site"
+  upp history "My Site" --limit 100
+  upp history "Price Watch" --value
+  upp history "Price Watch" --value --period 30d --json`,
+		Args: requireArgs(1),
+		Run:  runHistory,
 	}
 	cmd.Flags().IntP("limit", "l", 20, "Number of results to show")
+	cmd.Flags().Bool("value", false, "Show the tracked value series of a numeric target")
+	cmd.Flags().StringP("period", "p", "", "With --value, only show values from this period: 1h, 24h, 7d, 30d")
 	rootCmd.AddCommand(cmd)
 }
 
@@ -28,6 +44,12 @@ func runHistory(cmd *cobra.Command, args []string) {
 		exitError(err.Error())
 	}
 
+	if v, _ := cmd.Flags().GetBool("value"); v {
+		period, _ := cmd.Flags().GetString("period")
+		showValueHistory(t, limit, period)
+		return
+	}
+
 	results, err := db.GetCheckHistory(t.ID, limit)
 	if err != nil {
 		exitError(err.Error())
@@ -43,14 +65,114 @@ func runHistory(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	// Only show the phase breakdown when some result has one (HTTP checks)
+	showTiming := false
+	for _, r := range results {
+		if r.Timing != nil {
+			showTiming = true
+			break
+		}
+	}
+
+	// Heartbeat pings carry the job's output
+	showBody := false
+	for _, r := range results {
+		if r.Body != "" {
+			showBody = true
+			break
+		}
+	}
+
 	fmt.Printf("History for: %s (%s)\n\n", t.Name, t.URL)
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintf(w, "TIME\tSTATUS\tCODE\tRESPONSE\tERROR\n")
-	fmt.Fprintf(w, "────\t──────\t────\t────────\t─────\n")
+	if showTiming {
+		fmt.Fprintf(w, "TIME\tSTATUS\tCODE\tRESPONSE\tDNS\tCONNECT\tTLS\tTTFB\tTRANSFER\tERROR\n")
+		fmt.Fprintf(w, "────\t──────\t────\t────────\t───\t───────\t───\t────\t────────\t─────\n")
+	} else if showBody {
+		fmt.Fprintf(w, "TIME\tSTATUS\tCODE\tRESPONSE\tERROR\tBODY\n")
+		fmt.Fprintf(w, "────\t──────\t────\t────────\t─────\t────\n")
+	} else {
+		fmt.Fprintf(w, "TIME\tSTATUS\tCODE\tRESPONSE\tERROR\n")
+		fmt.Fprintf(w, "────\t──────\t────\t────────\t─────\n")
+	}
 
 	for _, r := range results {
-		fmt.Fprintf(w, "%s\t%s\t%d\t%dms\t%s\n",
-			r.CheckedAt.Format("2006-01-02 15:04:05"), r.Status, r.StatusCode, r.ResponseTime, r.Error)
+		fmt.Fprintf(w, "%s\t%s\t%d\t%dms\t",
+			r.CheckedAt.Format("2006-01-02 15:04:05"), r.Status, r.StatusCode, r.ResponseTime)
+		if showTiming {
+			if r.Timing != nil {
+				fmt.Fprintf(w, "%dms\t%dms\t%dms\t%dms\t%dms\t",
+					r.Timing.DNS, r.Timing.Connect, r.Timing.TLS, r.Timing.TTFB, r.Timing.Transfer)
+			} else {
+				fmt.Fprintf(w, "—\t—\t—\t—\t—\t")
+			}
+		}
+		if showBody {
+			fmt.Fprintf(w, "%s\t%s\n", r.Error, truncateStr(strings.Join(strings.Fields(r.Body), " "), 60))
+		} else {
+			fmt.Fprintf(w, "%s\n", r.Error)
+		}
+	}
+	w.Flush()
+}
+
+// showValueHistory prints a numeric target's values, newest first. With a
+// period, every value in it is shown regardless of the limit.
+func showValueHistory(t *db.Target, limit int, period string) {
+	var since time.Time
+	if period != "" {
+		since, limit = parsePeriod(period), 0
+	}
+	values, err := db.GetValues(t.ID, since, limit)
+	if err != nil {
+		exitError(err.Error())
+	}
+
+	if jsonOutput {
+		if values == nil {
+			values = []db.Value{}
+		}
+		printJSON(values)
+		return
+	}
+
+	if len(values) == 0 {
+		if !checker.TracksValue(t) {
+			fmt.Printf("%s does not track a value. Add one with: upp edit %q --numeric\n", t.Name, t.Name)
+		} else {
+			fmt.Println("No values recorded. Run 'upp check' first.")
+		}
+		return
+	}
+
+	fmt.Printf("Values for: %s (%s)\n\n", t.Name, t.URL)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "TIME\tVALUE\tCHANGE\n")
+	fmt.Fprintf(w, "────\t─────\t──────\n")
+	for i, v := range values {
+		change := "—"
+		if i+1 < len(values) {
+			change = formatValueChange(values[i+1].Value, v.Value)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", v.RecordedAt.Format("2006-01-02 15:04:05"), checker.FormatValue(v.Value), change)
 	}
 	w.Flush()
 }
+
+// formatValueChange describes the change between two values, e.g.
+// "-12.5 (-10.0%)".
+func formatValueChange(prev, cur float64) string {
+	// Round away float noise such as 95.1-100 = -4.900000000000006
+	diff := math.Round((cur-prev)*1e9) / 1e9
+	if diff == 0 {
+		return "0"
+	}
+	s := checker.FormatValue(diff)
+	if diff > 0 {
+		s = "+" + s
+	}
+	if prev != 0 {
+		s += fmt.Sprintf(" (%+.1f%%)", diff/math.Abs(prev)*100)
+	}
+	return s
+}
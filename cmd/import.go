@@ -3,7 +3,10 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/naru-bot/upp/internal/checker"
+	"github.com/naru-bot/upp/internal/config"
 	"github.com/naru-bot/upp/internal/db"
 	"github.com/spf13/cobra"
 	"gopkg.in/yaml.v3"
@@ -23,11 +26,43 @@ YAML format:
       interval: 60
       selector: "div.content"
       expect: "Welcome"
+      normalize:
+        ignore_regex: ["Updated \\d+ minutes ago"]
+        drop_selectors: [".ad", ".view-count"]
+        whitespace: true
       timeout: 10
       retries: 3
+      proxy: http://egress.corp.example:3128
+    - name: API Health
+      url: https://api.example.com/health
+      assertions:
+        - status in 200-299
+        - header Content-Type contains json
+        - jq .db.status == "ok"
+        - response_time < 800ms
+    - name: Internal API
+      url: https://api.internal/health
+      client_cert: /etc/upp/client.pem
+      client_key: /etc/upp/client-key.pem
+      ca_cert: /etc/upp/internal-ca.pem
     - name: MySQL
       url: 192.168.1.1:3306
       type: tcp
+    - name: Login flow
+      url: https://app.example.com
+      type: transaction
+      steps:
+        - name: login
+          method: POST
+          url: /login
+          body: '{"user":"me","password":"secret"}'
+          extract:
+            - name: token
+              jq: .token
+        - name: account
+          url: /account
+          headers: {Authorization: "Bearer {{token}}"}
+          expect: Welcome
 
 Examples:
   upp import targets.yml
@@ -59,6 +94,22 @@ type importTarget struct {
 	NoFollow      bool    `yaml:"no_follow"`
 	AcceptStatus  string  `yaml:"accept_status"`
 	Insecure      bool    `yaml:"insecure"`
+	ExpiryDays   int              `yaml:"expiry_days"`
+	Packets      int              `yaml:"packets"`
+	DegradedLoss float64          `yaml:"degraded_loss"`
+	MaxLoss      float64          `yaml:"max_loss"`
+	Resolver     string           `yaml:"resolver"`
+	Steps        []checker.Step   `yaml:"steps"`
+	Assertions   []string         `yaml:"assertions"`
+	Proxy        string           `yaml:"proxy"`
+	ClientCert   string           `yaml:"client_cert"`
+	ClientKey    string           `yaml:"client_key"`
+	CACert       string           `yaml:"ca_cert"`
+	Normalize    config.Normalize `yaml:"normalize"`
+	Render       string           `yaml:"render"`
+	Grace        int              `yaml:"grace"`
+	Numeric      string           `yaml:"numeric"`
+	WatchHeaders string           `yaml:"watch_headers"`
 }
 
 func runImport(cmd *cobra.Command, args []string) {
@@ -87,12 +138,60 @@ func runImport(cmd *cobra.Command, args []string) {
 	added := 0
 
 	for _, t := range imp.Targets {
-		if t.URL == "" {
+		if t.URL == "" && t.Type != "heartbeat" {
+			continue
+		}
+		var steps string
+		if len(t.Steps) > 0 {
+			if t.Type == "" {
+				t.Type = "transaction"
+			}
+			var err error
+			if steps, err = encodeSteps(t.Steps); err != nil {
+				results = append(results, result{Name: t.Name, URL: t.URL, Status: "error", Error: err.Error()})
+				if !jsonOutput {
+					fmt.Printf("  %s %s — %s\n", colorRed("✗"), t.Name, err)
+				}
+				continue
+			}
+		}
+		assertions, err := encodeAssertions(t.Assertions)
+		if err == nil {
+			err = checker.ValidateProxy(t.Proxy)
+		}
+		if err == nil {
+			err = checker.ValidateRender(t.Render)
+		}
+		if err == nil {
+			err = checker.ValidateNumeric(t.Numeric)
+		}
+		if err == nil {
+			err = checker.ValidateWatchHeaders(t.WatchHeaders)
+		}
+		var normalize string
+		if err == nil {
+			normalize, err = encodeNormalize(t.Normalize)
+		}
+		if err == nil {
+			t.ClientCert, t.ClientKey, t.CACert = certRef(t.ClientCert), certRef(t.ClientKey), certRef(t.CACert)
+			_, _, _, err = checker.LoadTLSFiles(t.ClientCert, t.ClientKey, t.CACert)
+		}
+		if err != nil {
+			results = append(results, result{Name: t.Name, URL: t.URL, Status: "error", Error: err.Error()})
+			if !jsonOutput {
+				fmt.Printf("  %s %s — %s\n", colorRed("✗"), t.Name, err)
+			}
 			continue
 		}
 		if t.Type == "" {
 			t.Type = "http"
 		}
+		// Heartbeats keep an exported token so jobs need not be reconfigured
+		if t.Type == "heartbeat" && !strings.HasPrefix(t.URL, "heartbeat://") {
+			if t.URL, err = checker.NewHeartbeatURL(); err != nil {
+				exitError(err.Error())
+			}
+		}
 		if t.Interval <= 0 {
 			t.Interval = 300
 		}
@@ -106,8 +205,10 @@ func runImport(cmd *cobra.Command, args []string) {
 			t.Threshold = 5.0
 		}
 
-		_, err := db.AddTarget(t.Name, t.URL, t.Type, t.Interval, t.Selector, t.Headers, t.Expect, t.Timeout, t.Retries, t.Threshold, db.AddTargetOpts{
-				TriggerRule: t.TriggerRule, JQFilter: t.JQFilter, Method: t.Method, Body: t.Body, NoFollow: t.NoFollow, AcceptStatus: t.AcceptStatus, Insecure: t.Insecure,
+		_, err = db.AddTarget(t.Name, t.URL, t.Type, t.Interval, t.Selector, t.Headers, t.Expect, t.Timeout, t.Retries, t.Threshold, db.AddTargetOpts{
+			TriggerRule: t.TriggerRule, JQFilter: t.JQFilter, Method: t.Method, Body: t.Body, NoFollow: t.NoFollow, AcceptStatus: t.AcceptStatus, Insecure: t.Insecure, ExpiryDays: t.ExpiryDays,
+			Packets: t.Packets, DegradedLoss: t.DegradedLoss, MaxLoss: t.MaxLoss, Resolver: t.Resolver, Steps: steps, Assertions: assertions, Proxy: t.Proxy,
+			ClientCert: t.ClientCert, ClientKey: t.ClientKey, CACert: t.CACert, Normalize: normalize, Render: t.Render, Grace: t.Grace, Numeric: t.Numeric, WatchHeaders: t.WatchHeaders,
 			})
 		r := result{Name: t.Name, URL: t.URL}
 		if err != nil {
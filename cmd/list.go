@@ -65,8 +65,8 @@ func runList(cmd *cobra.Command, args []string) {
 	tagMap, _ := db.GetTagMap()
 
 	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
-	fmt.Fprintf(w, "ID\tNAME\tURL\tTYPE\tINTERVAL\tTAGS\tSTATUS\n")
-	fmt.Fprintf(w, "──\t────\t───\t────\t────────\t────\t──────\n")
+	fmt.Fprintf(w, "ID\tNAME\tURL\tTYPE\tINTERVAL\tTAGS\tSTATUS\tNEXT CHECK\n")
+	fmt.Fprintf(w, "──\t────\t───\t────\t────────\t────\t──────\t──────────\n")
 
 	for _, t := range targets {
 		status := "active"
@@ -85,8 +85,8 @@ func runList(cmd *cobra.Command, args []string) {
 			tags = strings.Join(tt, ",")
 		}
 
-		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%ds\t%s\t%s\n",
-			t.ID, t.Name, truncate(t.URL, 40), t.Type, t.Interval, tags, status)
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%ds\t%s\t%s\t%s\n",
+			t.ID, t.Name, truncate(t.URL, 40), t.Type, t.Interval, tags, status, formatNextRun(&t))
 	}
 	w.Flush()
 }
@@ -123,6 +123,18 @@ func listTags() {
 	}
 }
 
+// formatNextRun describes when the daemon will next check a target.
+func formatNextRun(t *db.Target) string {
+	if t.Paused || t.NextRunAt == nil {
+		return "—"
+	}
+	until := time.Until(*t.NextRunAt).Round(time.Second)
+	if until <= 0 {
+		return "due"
+	}
+	return "in " + until.String()
+}
+
 func truncate(s string, max int) string {
 	if len(s) <= max {
 		return s
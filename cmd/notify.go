@@ -2,14 +2,46 @@ package cmd
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"text/tabwriter"
+	"time"
 
+	"github.com/naru-bot/upp/internal/alert"
 	"github.com/naru-bot/upp/internal/db"
+	"github.com/naru-bot/upp/internal/notify"
 	"github.com/spf13/cobra"
 )
 
+// Failed deliveries are retried after deliveryBackoff, doubling with every
+// attempt up to maxDeliveryBackoff, until maxDeliveryAttempts have failed.
+const (
+	deliveryBackoff     = 30 * time.Second
+	maxDeliveryBackoff  = time.Hour
+	maxDeliveryAttempts = 10
+)
+
+// deliveryLease is how long a sender holds a delivery it claimed. It
+// outlasts any send, so only a sender that died loses its claim.
+const deliveryLease = 5 * time.Minute
+
+// outageEvents are a target's state changes: once one is sent, older
+// undelivered ones are stale and dropped, so a queued "down" never arrives
+// after its "recovery" or reopens a resolved incident.
+var outageEvents = []string{alert.EventDown, alert.EventDegraded, alert.EventReminder, alert.EventRecovery}
+
+// routeEvents are the event types a route can be limited to. "error" is a
+// target going down because its check failed (e.g. a response that could
+// not be parsed) rather than because it is unreachable.
+var routeEvents = []string{alert.EventDown, alert.EventDegraded, alert.EventRecovery, alert.EventReminder, alert.EventChanged, alert.EventSSLExpiring, "error"}
+
+// previewEvents are the sample events 'notify preview' can render.
+var previewEvents = append(slices.Clone(routeEvents), "test")
+
 func init() {
 	notifyCmd := &cobra.Command{
 		Use:   "notify",
@@ -25,11 +57,37 @@ Examples:
   upp notify add --name alerts --type webhook --config '{"url":"https://hooks.slack.com/..."}'
   upp notify add --name telegram --type telegram --config '{"bot_token":"...","chat_id":"..."}'
   upp notify add --name discord --type discord --config '{"webhook_url":"..."}'
-  upp notify add --name runner --type command --config '{"command":"echo {target} is {status}"}'`,
+  upp notify add --name runner --type command --config '{"command":"echo {target} is {status}"}'
+  upp notify add --name ops --type email --config '{"host":"smtp.example.com","port":587,"username":"upp","password":"...","from":"upp@example.com","to":["ops@example.com"]}'
+  upp notify add --name oncall --type pagerduty --config '{"routing_key":"..."}'
+  upp notify add --name genie --type opsgenie --config '{"api_key":"...","tags":["web"]}'
+
+Command channels run their command with sh. {target}, {url}, {status} and
+{message} expand to the environment variables UPP_TARGET, UPP_URL,
+UPP_STATUS and UPP_MESSAGE (plus UPP_EVENT), never to shell code; put
+them in double quotes to keep them as one word.
+
+Email channels take host, port, security (starttls, tls or none; default
+tls on port 465, otherwise starttls), username, password, from and to.
+
+PagerDuty channels take the routing_key of an Events API v2 integration,
+Opsgenie channels the api_key of an API integration and optional tags.
+Both open one incident per target that its recovery resolves, and accept
+api_url (to use a mock or regional endpoint), dedup_prefix, and actions
+and severities maps overriding the defaults per event type. Changes and
+expiring certificates are ignored unless "actions" sets them to trigger.
+
+Every channel accepts a "template" (Go text/template) replacing its
+built-in message, rendered against the event: {{.Target}}, {{.URL}},
+{{.Tags}}, {{.Event}}, {{.Status}}, {{.PreviousStatus}}, {{.StatusCode}},
+{{.ResponseTime}}, {{.SSLDays}}, {{.Error}}, {{.Downtime}}, {{.Diff}},
+{{.DiffSummary}} and more; {{template "message" .}} is the default text.
+Email channels also accept "subject" and "html_template" (html/template).
+Use 'upp notify preview' to try them out.`,
 		Run: runNotifyAdd,
 	}
 	addCmd.Flags().String("name", "", "Name for this notification channel")
-	addCmd.Flags().String("type", "", "Type: webhook, command, slack, telegram, discord")
+	addCmd.Flags().String("type", "", "Type: webhook, command, slack, telegram, discord, email, pagerduty, opsgenie")
 	addCmd.Flags().String("config", "", "JSON configuration for the channel")
 	addCmd.MarkFlagRequired("name")
 	addCmd.MarkFlagRequired("type")
@@ -78,7 +136,150 @@ Examples:
 		},
 	}
 
-	notifyCmd.AddCommand(addCmd, listCmd, removeCmd)
+	routeCmd := &cobra.Command{
+		Use:   "route",
+		Short: "Route notifications of targets to channels",
+		Long: `Route notifications of targets to specific channels.
+
+Targets without routes notify every channel. Once a route applies to a
+target, by its name, one of its tags or --all, the target only notifies
+the channels of its routes, for the events they accept.
+
+Events: down, degraded, recovery, reminder, changed, ssl_expiring, error
+(a failed check; routes for down events get these too)`,
+	}
+
+	routeAddCmd := &cobra.Command{
+		Use:   "add",
+		Short: "Add a notification route",
+		Long: `Route the notifications of a target, of every target with a tag, or of
+all targets to a channel, optionally only for some events.
+
+Examples:
+  upp notify route add --channel oncall --tag production --events down,error,recovery
+  upp notify route add --channel personal --tag hobby
+  upp notify route add --channel oncall --target "API" --events ssl_expiring
+  upp notify route add --channel audit --all`,
+		Run: runNotifyRouteAdd,
+	}
+	routeAddCmd.Flags().String("channel", "", "Notification channel (name or id)")
+	routeAddCmd.Flags().String("target", "", "Route this target (name, url or id)")
+	routeAddCmd.Flags().String("tag", "", "Route every target with this tag")
+	routeAddCmd.Flags().Bool("all", false, "Route every target")
+	routeAddCmd.Flags().String("events", "", "Comma-separated event types to route (default: all)")
+	routeAddCmd.MarkFlagRequired("channel")
+	routeAddCmd.MarkFlagsOneRequired("target", "tag", "all")
+	routeAddCmd.MarkFlagsMutuallyExclusive("target", "tag", "all")
+
+	routeListCmd := &cobra.Command{
+		Use:     "list",
+		Short:   "List notification routes",
+		Aliases: []string{"ls"},
+		Run: func(cmd *cobra.Command, args []string) {
+			routes, err := db.ListNotifyRoutes()
+			if err != nil {
+				exitError(err.Error())
+			}
+			if jsonOutput {
+				if routes == nil {
+					routes = []db.NotifyRoute{}
+				}
+				printJSON(routes)
+				return
+			}
+			if len(routes) == 0 {
+				fmt.Println("No notification routes; every target notifies every channel.")
+				return
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintf(w, "ID\tCHANNEL\tTARGETS\tEVENTS\n")
+			for _, r := range routes {
+				fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", r.ID, r.Channel, describeRouteScope(r), describeRouteEvents(r.Events))
+			}
+			w.Flush()
+		},
+	}
+
+	routeRemoveCmd := &cobra.Command{
+		Use:   "remove <id>",
+		Short: "Remove a notification route",
+		Args:  requireArgs(1),
+		Run: func(cmd *cobra.Command, args []string) {
+			id, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				exitError("invalid route id: " + args[0])
+			}
+			if err := db.RemoveNotifyRoute(id); err != nil {
+				exitError(err.Error())
+			}
+			if jsonOutput {
+				printJSON(map[string]string{"status": "removed"})
+			} else {
+				fmt.Printf("✓ Removed notification route: %d\n", id)
+			}
+		},
+	}
+
+	logCmd := &cobra.Command{
+		Use:   "log [target]",
+		Short: "Show notification delivery attempts",
+		Long: `Show the latest notification delivery attempts, newest first, with the
+channel, event, HTTP status code, latency and error of each.
+
+Failed deliveries are retried by the daemon with exponential backoff
+(30s, 1m, 2m, ... up to 1h between attempts, 10 attempts in total).
+
+Examples:
+  upp notify log
+  upp notify log "My Site" --limit 50
+  upp notify log --failed`,
+		Args: cobra.MaximumNArgs(1),
+		Run:  runNotifyLog,
+	}
+	logCmd.Flags().Int("limit", 20, "Number of attempts to show")
+	logCmd.Flags().Bool("failed", false, "Only show failed attempts")
+
+	retryCmd := &cobra.Command{
+		Use:   "retry",
+		Short: "Retry undelivered notifications now",
+		Long: `Send every notification that has not been delivered yet right away,
+including ones the daemon gave up on.`,
+		Args: cobra.NoArgs,
+		Run:  runNotifyRetry,
+	}
+
+	testCmd := &cobra.Command{
+		Use:   "test <name|id>",
+		Short: "Send a test notification to a channel",
+		Long: `Send a test notification to a channel and report whether it was
+delivered. The attempt is logged but not retried.
+
+Examples:
+  upp notify test ops
+  upp notify test 2`,
+		Args: requireArgs(1),
+		Run:  runNotifyTest,
+	}
+
+	previewCmd := &cobra.Command{
+		Use:   "preview <name|id>",
+		Short: "Render a sample notification without sending it",
+		Long: `Render a sample event with a channel's template and print the message
+it would send, without sending anything.
+
+Examples:
+  upp notify preview ops
+  upp notify preview ops --event recovery
+  upp notify preview mail --event changed --target "My Site" --html`,
+		Args: requireArgs(1),
+		Run:  runNotifyPreview,
+	}
+	previewCmd.Flags().String("event", alert.EventDown, "Event to render: "+strings.Join(previewEvents, ", "))
+	previewCmd.Flags().String("target", "", "Use a target's name, URL and tags in the sample")
+	previewCmd.Flags().Bool("html", false, "Also print the HTML body of email channels")
+
+	routeCmd.AddCommand(routeAddCmd, routeListCmd, routeRemoveCmd)
+	notifyCmd.AddCommand(addCmd, listCmd, removeCmd, routeCmd, logCmd, retryCmd, testCmd, previewCmd)
 	rootCmd.AddCommand(notifyCmd)
 }
 
@@ -92,6 +293,9 @@ func runNotifyAdd(cmd *cobra.Command, args []string) {
 	if err := json.Unmarshal([]byte(config), &js); err != nil {
 		exitError("Invalid JSON config: " + err.Error())
 	}
+	if err := notify.Validate(typ, config); err != nil {
+		exitError(err.Error())
+	}
 
 	if err := db.SaveNotifyConfig(name, typ, config); err != nil {
 		exitError(err.Error())
@@ -103,3 +307,454 @@ func runNotifyAdd(cmd *cobra.Command, args []string) {
 		fmt.Printf("✓ Added notification channel: %s (%s)\n", name, typ)
 	}
 }
+
+func runNotifyRouteAdd(cmd *cobra.Command, args []string) {
+	channelRef, _ := cmd.Flags().GetString("channel")
+	targetRef, _ := cmd.Flags().GetString("target")
+	tag, _ := cmd.Flags().GetString("tag")
+	eventsFlag, _ := cmd.Flags().GetString("events")
+
+	channel, err := db.GetNotifyConfig(channelRef)
+	if err != nil {
+		exitError(err.Error())
+	}
+	route := db.NotifyRoute{ChannelID: channel.ID, Channel: channel.Name, Tag: strings.TrimSpace(tag)}
+	if targetRef != "" {
+		t, err := db.GetTarget(targetRef)
+		if err != nil {
+			exitError(err.Error())
+		}
+		route.TargetID, route.Target = t.ID, t.Name
+	}
+	if cmd.Flags().Changed("tag") && route.Tag == "" {
+		exitError("--tag must not be empty")
+	}
+	route.Events, err = parseRouteEvents(eventsFlag)
+	if err != nil {
+		exitError(err.Error())
+	}
+
+	route.ID, err = db.AddNotifyRoute(route.ChannelID, route.TargetID, route.Tag, route.Events)
+	if err != nil {
+		exitError(err.Error())
+	}
+	if jsonOutput {
+		printJSON(route)
+	} else {
+		fmt.Printf("✓ Added notification route %d: %s → %s (%s)\n", route.ID, describeRouteScope(route), route.Channel, describeRouteEvents(route.Events))
+	}
+}
+
+// parseRouteEvents validates a comma-separated --events list and returns it
+// normalized.
+func parseRouteEvents(spec string) (string, error) {
+	var events []string
+	for _, e := range strings.Split(spec, ",") {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if e == "" || slices.Contains(events, e) {
+			continue
+		}
+		if !slices.Contains(routeEvents, e) {
+			return "", fmt.Errorf("unknown event %q (must be one of %s)", e, strings.Join(routeEvents, ", "))
+		}
+		events = append(events, e)
+	}
+	return strings.Join(events, ","), nil
+}
+
+func describeRouteScope(r db.NotifyRoute) string {
+	switch {
+	case r.TargetID != 0:
+		return "target " + r.Target
+	case r.Tag != "":
+		return "tag " + r.Tag
+	}
+	return "all targets"
+}
+
+func describeRouteEvents(events string) string {
+	if events == "" {
+		return "all events"
+	}
+	return strings.ReplaceAll(events, ",", ", ")
+}
+
+// routeEvent returns the event type routes are matched against: a down
+// event caused by a failing check is an "error".
+func routeEvent(event, status string) string {
+	if event == alert.EventDown && status == "error" {
+		return "error"
+	}
+	return event
+}
+
+// targetRoutes returns the routes that apply to a target: its own, those of
+// its tags and those for all targets.
+func targetRoutes(t *db.Target) ([]db.NotifyRoute, error) {
+	routes, err := db.ListNotifyRoutes()
+	if err != nil || len(routes) == 0 {
+		return nil, err
+	}
+	tags, err := db.GetTags(t.ID)
+	if err != nil {
+		return nil, err
+	}
+	var applied []db.NotifyRoute
+	for _, r := range routes {
+		if r.TargetID == t.ID || (r.Tag != "" && slices.Contains(tags, r.Tag)) || (r.TargetID == 0 && r.Tag == "") {
+			applied = append(applied, r)
+		}
+	}
+	return applied, nil
+}
+
+// routeAccepts reports whether a route passes an event type. An "error" is
+// a kind of down, so routes for down events also pass it.
+func routeAccepts(r db.NotifyRoute, event string) bool {
+	if r.Events == "" {
+		return true
+	}
+	events := strings.Split(r.Events, ",")
+	return slices.Contains(events, event) || (event == "error" && slices.Contains(events, alert.EventDown))
+}
+
+// routeChannels returns the enabled channels an event of a target is sent
+// to: every channel when no route applies to the target, otherwise the
+// channels of its routes accepting the event.
+func routeChannels(t *db.Target, event string) ([]db.NotifyConfig, error) {
+	configs, err := db.ListNotifyConfigs()
+	if err != nil {
+		return nil, err
+	}
+	routes, err := targetRoutes(t)
+	if err != nil {
+		return nil, err
+	}
+	var channels []db.NotifyConfig
+	for _, c := range configs {
+		if !c.Enabled {
+			continue
+		}
+		accepted := len(routes) == 0
+		for _, r := range routes {
+			if r.ChannelID == c.ID && routeAccepts(r, event) {
+				accepted = true
+				break
+			}
+		}
+		if accepted {
+			channels = append(channels, c)
+		}
+	}
+	return channels, nil
+}
+
+// reachedChannel is a channel a target notifies and the events it gets;
+// no events means all of them.
+type reachedChannel struct {
+	Channel string   `json:"channel"`
+	Events  []string `json:"events,omitempty"`
+	Enabled bool     `json:"enabled"`
+}
+
+// reachedChannels lists the channels a target's notifications go to,
+// merging the events of routes to the same channel.
+func reachedChannels(t *db.Target) ([]reachedChannel, error) {
+	configs, err := db.ListNotifyConfigs()
+	if err != nil {
+		return nil, err
+	}
+	routes, err := targetRoutes(t)
+	if err != nil {
+		return nil, err
+	}
+	reached := []reachedChannel{}
+	for _, c := range configs {
+		ch := reachedChannel{Channel: c.Name, Enabled: c.Enabled}
+		routed, allEvents := len(routes) == 0, len(routes) == 0
+		for _, r := range routes {
+			if r.ChannelID != c.ID {
+				continue
+			}
+			routed = true
+			if r.Events == "" {
+				allEvents = true
+			}
+			for _, e := range strings.Split(r.Events, ",") {
+				if e != "" && !slices.Contains(ch.Events, e) {
+					ch.Events = append(ch.Events, e)
+				}
+			}
+		}
+		if !routed {
+			continue
+		}
+		if allEvents {
+			ch.Events = nil
+		}
+		reached = append(reached, ch)
+	}
+	return reached, nil
+}
+
+// deliver sends an event to a channel, logging the attempt and queuing a
+// retry when it fails.
+func deliver(c db.NotifyConfig, targetID int64, ev notify.Event) {
+	ev.Channel = c.Name
+	payload, err := json.Marshal(ev)
+	if err != nil {
+		return
+	}
+	id, err := db.CreateDelivery(c.ID, targetID, ev.Event, string(payload), time.Now().Add(deliveryLease))
+	if err != nil {
+		// Without a queue entry the alert can't be retried, but it can
+		// still be sent
+		notify.Send(c.Type, c.Config, ev)
+		return
+	}
+	attemptDelivery(db.NotifyDelivery{ID: id, ChannelID: c.ID, ChannelType: c.Type, ChannelConfig: c.Config, TargetID: targetID}, ev)
+}
+
+// attemptDelivery sends a delivery once and records the outcome: sent,
+// pending another attempt, or failed for good.
+func attemptDelivery(d db.NotifyDelivery, ev notify.Event) error {
+	start := time.Now()
+	code, err := notify.Send(d.ChannelType, d.ChannelConfig, ev)
+	latency := time.Since(start)
+	warning := sendWarning(&err)
+
+	attempts := d.Attempts + 1
+	status, errMsg := db.DeliverySent, ""
+	var next *time.Time
+	if err != nil {
+		errMsg = err.Error()
+		status = db.DeliveryFailed
+		if attempts < maxDeliveryAttempts {
+			status = db.DeliveryPending
+			due := time.Now().Add(deliveryRetryDelay(attempts))
+			next = &due
+		}
+	}
+	db.RecordDeliveryAttempt(d.ID, code, errMsg, warning, latency.Milliseconds(), status, next)
+	if err == nil && d.TargetID != 0 && slices.Contains(outageEvents, ev.Event) {
+		db.SupersedeDeliveries(d.ChannelID, d.TargetID, d.ID, outageEvents)
+	}
+	return err
+}
+
+// sendWarning clears an error of notify.Send that didn't stop the delivery,
+// a channel template that failed and was replaced by the default, and
+// returns it as a warning for the delivery log.
+func sendWarning(err *error) string {
+	var tmplErr *notify.TemplateError
+	if !errors.As(*err, &tmplErr) {
+		return ""
+	}
+	*err = nil
+	return tmplErr.Error()
+}
+
+// deliveryRetryDelay is the wait after a delivery's attempts-th failure.
+func deliveryRetryDelay(attempts int) time.Duration {
+	delay := deliveryBackoff
+	for i := 1; i < attempts && delay < maxDeliveryBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDeliveryBackoff)
+}
+
+// retryDeliveries attempts each delivery again and returns how many were
+// attempted and sent. Deliveries claimed by another sender or superseded
+// since they were listed are skipped.
+func retryDeliveries(deliveries []db.NotifyDelivery) (attempted, sent int) {
+	for _, d := range deliveries {
+		if ok, err := db.ClaimDelivery(d, time.Now().Add(deliveryLease)); err != nil || !ok {
+			continue
+		}
+		attempted++
+		var ev notify.Event
+		if err := json.Unmarshal([]byte(d.Payload), &ev); err != nil {
+			db.RecordDeliveryAttempt(d.ID, 0, "invalid queued payload: "+err.Error(), "", 0, db.DeliveryFailed, nil)
+			continue
+		}
+		if attemptDelivery(d, ev) == nil {
+			sent++
+		}
+	}
+	return attempted, sent
+}
+
+func runNotifyLog(cmd *cobra.Command, args []string) {
+	limit, _ := cmd.Flags().GetInt("limit")
+	failed, _ := cmd.Flags().GetBool("failed")
+
+	var targetID int64
+	if len(args) > 0 {
+		t, err := db.GetTarget(args[0])
+		if err != nil {
+			exitError(err.Error())
+		}
+		targetID = t.ID
+	}
+	attempts, err := db.ListDeliveryAttempts(targetID, failed, limit)
+	if err != nil {
+		exitError(err.Error())
+	}
+	pending, _ := db.CountPendingDeliveries()
+
+	if jsonOutput {
+		if attempts == nil {
+			attempts = []db.NotifyAttempt{}
+		}
+		printJSON(map[string]interface{}{"pending": pending, "attempts": attempts})
+		return
+	}
+
+	if len(attempts) == 0 {
+		fmt.Println("No notification deliveries recorded.")
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "TIME\tCHANNEL\tTARGET\tEVENT\tRESULT\tCODE\tLATENCY\tERROR\n")
+	for _, a := range attempts {
+		result := "sent"
+		if a.Error != "" {
+			result = "failed"
+			if !noColor {
+				result = colorRed(result)
+			}
+		} else if !noColor {
+			result = colorGreen(result)
+		}
+		msg := a.Error
+		if msg == "" && a.Warning != "" {
+			msg = "warning: " + a.Warning
+			if !noColor {
+				msg = colorYellow(msg)
+			}
+		}
+		code := "-"
+		if a.StatusCode != 0 {
+			code = strconv.Itoa(a.StatusCode)
+		}
+		channel := a.Channel
+		if channel == "" {
+			channel = "(removed)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%dms\t%s\n",
+			a.AttemptedAt.Local().Format("2006-01-02 15:04:05"), channel, a.Target, a.Event, result, code, a.LatencyMs, msg)
+	}
+	w.Flush()
+	if pending > 0 {
+		fmt.Printf("\n%d notification(s) waiting for a retry (run 'upp notify retry' to send them now)\n", pending)
+	}
+}
+
+func runNotifyRetry(cmd *cobra.Command, args []string) {
+	deliveries, err := db.ListUndelivered(time.Now())
+	if err != nil {
+		exitError(err.Error())
+	}
+	retried, sent := retryDeliveries(deliveries)
+	failed := retried - sent
+
+	if jsonOutput {
+		printJSON(map[string]int{"retried": retried, "sent": sent, "failed": failed})
+		return
+	}
+	if retried == 0 {
+		fmt.Println("No undelivered notifications.")
+		return
+	}
+	fmt.Printf("✓ Retried %d notification(s): %d sent, %d failed\n", retried, sent, failed)
+	if failed > 0 {
+		fmt.Println("See 'upp notify log --failed' for the errors.")
+	}
+}
+
+func runNotifyTest(cmd *cobra.Command, args []string) {
+	c, err := db.GetNotifyConfig(args[0])
+	if err != nil {
+		exitError(err.Error())
+	}
+	ev := notify.Event{
+		Target:  "upp",
+		Channel: c.Name,
+		Event:   "test",
+		Status:  "up",
+		Time:    time.Now().UTC().Format(time.RFC3339),
+	}
+
+	start := time.Now()
+	code, sendErr := notify.Send(c.Type, c.Config, ev)
+	latency := time.Since(start)
+	warning := sendWarning(&sendErr)
+	status, errMsg := db.DeliverySent, ""
+	if sendErr != nil {
+		status, errMsg = db.DeliveryFailed, sendErr.Error()
+	}
+	payload, _ := json.Marshal(ev)
+	if id, err := db.CreateDelivery(c.ID, 0, ev.Event, string(payload), time.Now()); err == nil {
+		db.RecordDeliveryAttempt(id, code, errMsg, warning, latency.Milliseconds(), status, nil)
+	}
+
+	if jsonOutput {
+		printJSON(map[string]interface{}{"channel": c.Name, "status": status, "status_code": code, "latency_ms": latency.Milliseconds(), "error": errMsg, "warning": warning})
+		return
+	}
+	if sendErr != nil {
+		exitError(fmt.Sprintf("test notification to %s failed: %s", c.Name, errMsg))
+	}
+	fmt.Printf("✓ Sent test notification to %s (%dms)\n", c.Name, latency.Milliseconds())
+	if warning != "" {
+		fmt.Println(colorYellow("Warning: " + warning))
+	}
+}
+
+func runNotifyPreview(cmd *cobra.Command, args []string) {
+	event, _ := cmd.Flags().GetString("event")
+	targetRef, _ := cmd.Flags().GetString("target")
+	showHTML, _ := cmd.Flags().GetBool("html")
+
+	c, err := db.GetNotifyConfig(args[0])
+	if err != nil {
+		exitError(err.Error())
+	}
+	event = strings.ToLower(event)
+	if !slices.Contains(previewEvents, event) {
+		exitError(fmt.Sprintf("unknown event %q (must be one of %s)", event, strings.Join(previewEvents, ", ")))
+	}
+	ev := notify.SampleEvent(event)
+	ev.Channel = c.Name
+	if targetRef != "" {
+		t, err := db.GetTarget(targetRef)
+		if err != nil {
+			exitError(err.Error())
+		}
+		ev.TargetID, ev.Target, ev.URL = t.ID, t.Name, t.URL
+		ev.Tags, _ = db.GetTags(t.ID)
+	}
+
+	r, err := notify.Render(c.Type, c.Config, ev)
+	warning := sendWarning(&err)
+	if err != nil {
+		exitError(err.Error())
+	}
+	if jsonOutput {
+		printJSON(struct {
+			*notify.Rendered
+			Warning string `json:"warning,omitempty"`
+		}{r, warning})
+		return
+	}
+	if warning != "" {
+		fmt.Fprintln(os.Stderr, colorYellow("Warning: "+warning))
+	}
+	if r.Subject != "" {
+		fmt.Printf("%s %s\n\n", colorBold("Subject:"), r.Subject)
+	}
+	fmt.Println(r.Message)
+	if showHTML && r.HTML != "" {
+		fmt.Printf("\n%s\n%s", colorBold("HTML:"), r.HTML)
+	}
+}
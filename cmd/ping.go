@@ -23,16 +23,24 @@ Examples:
   upp ping https://example.com --selector "h1"
   upp ping 192.168.1.1:3306 --type tcp
   upp ping example.com --type dns
+  upp ping example.com --type dns --record MX --resolver 8.8.8.8
+  upp ping example.com --type ping --packets 10
+  upp ping imap.example.com:993 --type tls
   upp ping https://api.example.com --expect "ok"
+  upp ping https://intranet.corp.example --proxy http://egress.corp.example:3128
   upp ping https://example.com --count 5`,
 		Args: requireArgs(1),
 		Run:  runPing,
 	}
-	cmd.Flags().StringP("type", "t", "http", "Check type: http, tcp, ping, dns")
+	cmd.Flags().StringP("type", "t", "http", "Check type: http, tcp, ping, dns, tls")
 	cmd.Flags().StringP("selector", "s", "", "CSS selector to extract")
-	cmd.Flags().String("expect", "", "Expected keyword in response body")
+	cmd.Flags().String("expect", "", "Expected keyword in response body (dns: comma-separated expected record values)")
+	cmd.Flags().String("record", "", "DNS record type to query (dns type)")
+	cmd.Flags().String("resolver", "", "Nameserver to query (dns type)")
 	cmd.Flags().IntP("count", "c", 1, "Number of checks to run")
+	cmd.Flags().Int("packets", 0, "Echo requests per check (ping type, default 5)")
 	cmd.Flags().Int("timeout", 30, "Timeout in seconds")
+	cmd.Flags().String("proxy", "", "Proxy URL for http checks (http://, https://, socks5://) or 'direct'")
 	rootCmd.AddCommand(cmd)
 }
 
@@ -45,6 +53,8 @@ type pingOutput struct {
 	SSLDaysLeft  *int   `json:"ssl_days_left,omitempty"`
 	BodyMatch    *bool  `json:"body_match,omitempty"`
 	Error        string `json:"error,omitempty"`
+	Timing      *db.Timing `json:"timing,omitempty"`
+	Ping        *pingStats `json:"ping,omitempty"`
 }
 
 func runPing(cmd *cobra.Command, args []string) {
@@ -53,6 +63,11 @@ func runPing(cmd *cobra.Command, args []string) {
 	selector, _ := cmd.Flags().GetString("selector")
 	expect, _ := cmd.Flags().GetString("expect")
 	count, _ := cmd.Flags().GetInt("count")
+	packets, _ := cmd.Flags().GetInt("packets")
+	resolver, _ := cmd.Flags().GetString("resolver")
+	if record := recordFlag(cmd, typ); record != "" {
+		selector = record
+	}
 
 	// Create a temporary target (not saved to DB)
 	target := &db.Target{
@@ -60,6 +75,14 @@ func runPing(cmd *cobra.Command, args []string) {
 		Name:     url,
 		Type:     typ,
 		Selector: selector,
+		Packets:  packets,
+		Resolver: resolver,
+		Proxy:    proxyFlag(cmd),
+	}
+	// DNS record checks compare expected values themselves
+	if typ == "dns" && selector != "" {
+		target.Expect = expect
+		expect = ""
 	}
 
 	var outputs []pingOutput
@@ -75,6 +98,8 @@ func runPing(cmd *cobra.Command, args []string) {
 			ResponseMs:  result.ResponseTime.Milliseconds(),
 			ContentHash: result.ContentHash,
 			Error:       result.Error,
+			Timing:      dbTiming(result.Timing),
+			Ping:        newPingStats(result.Ping),
 		}
 
 		if result.SSLExpiry != nil {
@@ -102,6 +127,8 @@ func runPing(cmd *cobra.Command, args []string) {
 				icon = colorGreen("✓")
 			} else if result.Status == "down" || result.Status == "error" {
 				icon = colorRed("✗")
+			} else if result.Status == "degraded" {
+				icon = colorYellow("⚠")
 			}
 
 			if count > 1 {
@@ -134,6 +161,12 @@ func runPing(cmd *cobra.Command, args []string) {
 				}
 			}
 			fmt.Println()
+			if out.Timing != nil {
+				fmt.Printf("  %s\n", formatTiming(out.Timing))
+			}
+			if out.Ping != nil {
+				fmt.Printf("  %s\n", formatPingStats(out.Ping))
+			}
 		}
 
 		if count > 1 && i < count-1 {
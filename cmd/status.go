@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"fmt"
+	"math"
 	"os"
 	"regexp"
 	"strings"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/mattn/go-runewidth"
 
+	"github.com/naru-bot/upp/internal/checker"
 	"github.com/naru-bot/upp/internal/db"
 	"github.com/spf13/cobra"
 )
@@ -16,7 +18,8 @@ import (
 // Available columns for status output
 var availableColumns = []string{
 	"name", "url", "type", "tags", "uptime", "avg", "min", "max",
-	"checks", "changes", "trend", "status", "last_checked", "interval",
+	"ttfb", "checks", "changes", "trend", "ttfb_trend", "status",
+	"last_checked", "interval", "value", "value_min", "value_max", "value_trend",
 }
 
 var defaultColumns = []string{
@@ -32,8 +35,16 @@ func init() {
 Without arguments, shows summary for all targets.
 
 Customize columns with --columns (comma-separated):
-  name, url, type, tags, uptime, avg, min, max,
-  checks, changes, trend, status, last_checked, interval
+  name, url, type, tags, uptime, avg, min, max, ttfb,
+  checks, changes, trend, ttfb_trend, status, last_checked, interval,
+  value, value_min, value_max, value_trend
+
+The ttfb columns show time to first byte for HTTP targets, separately
+from the total response time graphed by trend.
+
+The value columns show the latest, lowest and highest value of targets
+tracking a number (--numeric) over the period, and its trend. They are
+added to the default columns when any listed target tracks a number.
 
 Examples:
   upp status
@@ -42,6 +53,8 @@ Examples:
   upp status --tag my-sites
   upp status --columns name,uptime,avg,status
   upp status --columns name,url,tags,uptime,trend,status
+  upp status --columns name,avg,ttfb,trend,ttfb_trend
+  upp status --columns name,value,value_min,value_max,value_trend --period 30d
   upp status --columns all`,
 		Run: runStatus,
 	}
@@ -52,21 +65,27 @@ Examples:
 }
 
 type statusOutput struct {
-	Target        string  `json:"target"`
-	URL           string  `json:"url"`
-	Type          string  `json:"type"`
-	Tags          string  `json:"tags,omitempty"`
-	UptimePercent float64 `json:"uptime_percent"`
-	AvgResponseMs float64 `json:"avg_response_ms"`
-	MinResponseMs int64   `json:"min_response_ms"`
-	MaxResponseMs int64   `json:"max_response_ms"`
-	TotalChecks   int     `json:"total_checks"`
-	LastStatus    string  `json:"last_status"`
-	LastError     string  `json:"last_error,omitempty"`
-	LastChecked   string  `json:"last_checked,omitempty"`
-	Changes       int     `json:"content_changes"`
-	Sparkline     string  `json:"sparkline,omitempty"`
-	Interval      int     `json:"interval_seconds"`
+	Target         string   `json:"target"`
+	URL            string   `json:"url"`
+	Type           string   `json:"type"`
+	Tags           string   `json:"tags,omitempty"`
+	UptimePercent  float64  `json:"uptime_percent"`
+	AvgResponseMs  float64  `json:"avg_response_ms"`
+	MinResponseMs  int64    `json:"min_response_ms"`
+	MaxResponseMs  int64    `json:"max_response_ms"`
+	AvgTTFBMs      float64  `json:"avg_ttfb_ms,omitempty"`
+	TotalChecks    int      `json:"total_checks"`
+	LastStatus     string   `json:"last_status"`
+	LastError      string   `json:"last_error,omitempty"`
+	LastChecked    string   `json:"last_checked,omitempty"`
+	Changes        int      `json:"content_changes"`
+	Sparkline      string   `json:"sparkline,omitempty"`
+	TTFBSparkline  string   `json:"ttfb_sparkline,omitempty"`
+	Interval       int      `json:"interval_seconds"`
+	Value          *float64 `json:"value,omitempty"`
+	ValueMin       *float64 `json:"value_min,omitempty"`
+	ValueMax       *float64 `json:"value_max,omitempty"`
+	ValueSparkline string   `json:"value_sparkline,omitempty"`
 }
 
 func parseColumns(input string) []string {
@@ -121,18 +140,30 @@ func columnHeader(col string) string {
 		return "MIN RESP"
 	case "max":
 		return "MAX RESP"
+	case "ttfb":
+		return "AVG TTFB"
 	case "checks":
 		return "CHECKS"
 	case "changes":
 		return "CHANGES"
 	case "trend":
 		return "TREND"
+	case "ttfb_trend":
+		return "TTFB TREND"
 	case "status":
 		return "STATUS"
 	case "last_checked":
 		return "LAST CHECKED"
 	case "interval":
 		return "INTERVAL"
+	case "value":
+		return "VALUE"
+	case "value_min":
+		return "MIN VALUE"
+	case "value_max":
+		return "MAX VALUE"
+	case "value_trend":
+		return "VALUE TREND"
 	default:
 		return strings.ToUpper(col)
 	}
@@ -171,12 +202,19 @@ func columnValue(col string, o *statusOutput) string {
 		return fmt.Sprintf("%dms", o.MinResponseMs)
 	case "max":
 		return fmt.Sprintf("%dms", o.MaxResponseMs)
+	case "ttfb":
+		if o.TTFBSparkline == "" {
+			return "—"
+		}
+		return fmt.Sprintf("%.0fms", o.AvgTTFBMs)
 	case "checks":
 		return fmt.Sprintf("%d", o.TotalChecks)
 	case "changes":
 		return fmt.Sprintf("%d", o.Changes)
 	case "trend":
 		return o.Sparkline
+	case "ttfb_trend":
+		return o.TTFBSparkline
 	case "status":
 		s := o.LastStatus
 		if !noColor && !jsonOutput {
@@ -185,11 +223,13 @@ func columnValue(col string, o *statusOutput) string {
 				s = colorGreen("● " + o.LastStatus)
 			case "changed":
 				s = colorYellow("△ " + o.LastStatus)
+			case "degraded":
+				s = colorYellow("⚠ " + o.LastStatus)
 			case "down", "error":
 				s = colorRed("✗ " + o.LastStatus)
 			}
 		}
-		if o.LastError != "" && (o.LastStatus == "down" || o.LastStatus == "error") {
+		if o.LastError != "" && (o.LastStatus == "down" || o.LastStatus == "error" || o.LastStatus == "degraded") {
 			shortErr := shortenError(o.LastError)
 			if !noColor && !jsonOutput {
 				shortErr = colorRed(shortErr)
@@ -207,6 +247,14 @@ func columnValue(col string, o *statusOutput) string {
 		return o.LastChecked
 	case "interval":
 		return fmt.Sprintf("%ds", o.Interval)
+	case "value":
+		return formatOptionalValue(o.Value)
+	case "value_min":
+		return formatOptionalValue(o.ValueMin)
+	case "value_max":
+		return formatOptionalValue(o.ValueMax)
+	case "value_trend":
+		return o.ValueSparkline
 	default:
 		return ""
 	}
@@ -217,7 +265,6 @@ func runStatus(cmd *cobra.Command, args []string) {
 	since := parsePeriod(period)
 	tag, _ := cmd.Flags().GetString("tag")
 	colStr, _ := cmd.Flags().GetString("columns")
-	cols := parseColumns(colStr)
 
 	var targets []db.Target
 	if len(args) > 0 {
@@ -249,6 +296,17 @@ func runStatus(cmd *cobra.Command, args []string) {
 		return
 	}
 
+	cols := parseColumns(colStr)
+	if colStr == "" {
+		for _, t := range targets {
+			if checker.TracksValue(&t) {
+				// Show the value before the status column
+				cols = append(append([]string{}, cols[:len(cols)-1]...), "value", "value_trend", "status")
+				break
+			}
+		}
+	}
+
 	// Load tags if needed
 	var tagMap map[int64][]string
 	for _, c := range cols {
@@ -277,7 +335,8 @@ func runStatus(cmd *cobra.Command, args []string) {
 		lastError := ""
 		lastChecked := ""
 		var minMs, maxMs int64
-		var responseTimes []int64
+		var responseTimes, ttfbTimes []int64
+		var ttfbSum int64
 		for i, r := range results {
 			if i == 0 {
 				lastStatus = r.Status
@@ -296,9 +355,17 @@ func runStatus(cmd *cobra.Command, args []string) {
 				maxMs = r.ResponseTime
 			}
 			responseTimes = append(responseTimes, r.ResponseTime)
+			if r.Timing != nil {
+				ttfbTimes = append(ttfbTimes, r.Timing.TTFB)
+				ttfbSum += r.Timing.TTFB
+			}
 		}
 
 		spark := buildSparkline(responseTimes, 20)
+		var avgTTFB float64
+		if len(ttfbTimes) > 0 {
+			avgTTFB = float64(ttfbSum) / float64(len(ttfbTimes))
+		}
 
 		tags := ""
 		if tagMap != nil {
@@ -307,22 +374,41 @@ func runStatus(cmd *cobra.Command, args []string) {
 			}
 		}
 
+		// Values are listed newest first, like check results
+		var values []float64
+		if checker.TracksValue(&t) {
+			series, _ := db.GetValues(t.ID, since, 0)
+			for _, v := range series {
+				values = append(values, v.Value)
+			}
+		}
+
 		out := statusOutput{
-			Target:        t.Name,
-			URL:           t.URL,
-			Type:          t.Type,
-			Tags:          tags,
-			UptimePercent: uptimePct,
-			AvgResponseMs: avgMs,
-			MinResponseMs: minMs,
-			MaxResponseMs: maxMs,
-			TotalChecks:   total,
-			LastStatus:    lastStatus,
-			LastError:     lastError,
-			LastChecked:   lastChecked,
-			Changes:       changes,
-			Sparkline:     spark,
-			Interval:      t.Interval,
+			Target:         t.Name,
+			URL:            t.URL,
+			Type:           t.Type,
+			Tags:           tags,
+			UptimePercent:  uptimePct,
+			AvgResponseMs:  avgMs,
+			MinResponseMs:  minMs,
+			MaxResponseMs:  maxMs,
+			AvgTTFBMs:      avgTTFB,
+			TotalChecks:    total,
+			LastStatus:     lastStatus,
+			LastError:      lastError,
+			LastChecked:    lastChecked,
+			Changes:        changes,
+			Sparkline:      spark,
+			TTFBSparkline:  buildSparkline(ttfbTimes, 20),
+			Interval:       t.Interval,
+			ValueSparkline: buildValueSparkline(values, 20),
+		}
+		if len(values) > 0 {
+			minV, maxV := values[0], values[0]
+			for _, v := range values {
+				minV, maxV = math.Min(minV, v), math.Max(maxV, v)
+			}
+			out.Value, out.ValueMin, out.ValueMax = &values[0], &minV, &maxV
 		}
 		outputs = append(outputs, out)
 	}
@@ -411,11 +497,21 @@ func shortenError(err string) string {
 }
 
 func buildSparkline(values []int64, maxLen int) string {
+	floats := make([]float64, len(values))
+	for i, v := range values {
+		floats[i] = float64(v)
+	}
+	return buildValueSparkline(floats, maxLen)
+}
+
+// buildValueSparkline draws the newest maxLen values, given newest first,
+// oldest to newest.
+func buildValueSparkline(values []float64, maxLen int) string {
 	if len(values) == 0 {
 		return ""
 	}
 
-	reversed := make([]int64, len(values))
+	reversed := make([]float64, len(values))
 	for i, v := range values {
 		reversed[len(values)-1-i] = v
 	}
@@ -442,7 +538,7 @@ func buildSparkline(values []int64, maxLen int) string {
 
 	var result []rune
 	for _, v := range reversed {
-		idx := int(float64(v-min) / float64(spread) * float64(len(blocks)-1))
+		idx := int((v - min) / spread * float64(len(blocks)-1))
 		if idx >= len(blocks) {
 			idx = len(blocks) - 1
 		}
@@ -451,6 +547,13 @@ func buildSparkline(values []int64, maxLen int) string {
 	return string(result)
 }
 
+func formatOptionalValue(v *float64) string {
+	if v == nil {
+		return "—"
+	}
+	return checker.FormatValue(*v)
+}
+
 func parsePeriod(p string) time.Time {
 	now := time.Now()
 	switch p {
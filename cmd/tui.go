@@ -14,7 +14,9 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/naru-bot/upp/internal/checker"
+	"github.com/naru-bot/upp/internal/config"
 	"github.com/naru-bot/upp/internal/db"
+	"github.com/naru-bot/upp/internal/engine"
 	"github.com/naru-bot/upp/internal/trigger"
 	"github.com/spf13/cobra"
 )
@@ -40,8 +42,12 @@ Keybindings:
   ?         Toggle help
   q/Esc     Quit`,
 		Run: func(cmd *cobra.Command, args []string) {
-			p := tea.NewProgram(newTUIModel(), tea.WithAltScreen())
-			if _, err := p.Run(); err != nil {
+			m := newTUIModel()
+			p := tea.NewProgram(m, tea.WithAltScreen())
+			_, err := p.Run()
+			// Don't leave checks running (or browsers open) after quitting
+			m.engine.Abort()
+			if err != nil {
 				exitError(err.Error())
 			}
 		},
@@ -117,7 +123,10 @@ var editFieldLabels = [editFieldCount]string{
 	"Name", "URL", "Type", "Interval (s)", "Timeout (s)", "Retries", "Selector", "Expect", "Threshold (%)", "Trigger If", "jq Filter", "Tags",
 }
 
-var typeOptions = []string{"http", "tcp", "ping", "dns", "visual", "whois"}
+// typeOptions are the check types the edit form cycles through;
+// transaction and heartbeat targets need steps or a ping token set up by
+// upp add or upp edit.
+var typeOptions = []string{"http", "tcp", "ping", "dns", "tls", "feed", "sitemap", "security", "visual", "whois"}
 
 func nextType(current string) string {
 	for i, t := range typeOptions {
@@ -190,6 +199,7 @@ type tuiModel struct {
 	filtered   []db.Target // filtered view of targets
 	results    map[int64]*checker.Result
 	checkingIDs map[int64]bool
+	engine         *engine.Engine
 	view       view
 	selected   *db.Target
 	detail     string
@@ -252,6 +262,7 @@ func newTUIModel() tuiModel {
 		table:       t,
 		results:     make(map[int64]*checker.Result),
 		checkingIDs: make(map[int64]bool),
+		engine:      engine.New(config.Get().MaxConcurrency(), config.Get().ExpensiveConcurrency()),
 		help:        help.New(),
 		status:      "Loading...",
 		searchInput: si,
@@ -529,23 +540,10 @@ func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case checkDoneMsg:
 		delete(m.checkingIDs, msg.targetID)
-		m.results[msg.targetID] = msg.result
-		// Save result to DB
-		cr := &db.CheckResult{
-			TargetID:     msg.targetID,
-			Status:       msg.result.Status,
-			StatusCode:   msg.result.StatusCode,
-			ResponseTime: msg.result.ResponseTime.Milliseconds(),
-			ContentHash:  msg.result.ContentHash,
-			Error:        msg.result.Error,
-		}
-		db.SaveCheckResult(cr)
-		if msg.result.Content != "" && msg.result.ContentHash != "" {
-			snaps, _ := db.GetLatestSnapshots(msg.targetID, 1)
-			if len(snaps) == 0 || snaps[0].Hash != msg.result.ContentHash {
-				db.SaveSnapshot(msg.targetID, msg.result.Content, msg.result.ContentHash)
-			}
+		if msg.result.Canceled {
+			return m, nil
 		}
+		m.results[msg.targetID] = msg.result
 		m.refreshData()
 		m.status = fmt.Sprintf("Checked | %d targets | %s", len(m.filtered), time.Now().Format("15:04:05"))
 		if m.view == viewDetail && m.selected != nil && m.selected.ID == msg.targetID {
@@ -722,6 +720,8 @@ func (m *tuiModel) updateDetail() {
 				icon = "✓"
 			case "changed":
 				icon = "△"
+			case "degraded":
+				icon = "⚠"
 			case "down", "error":
 				icon = "✗"
 			}
@@ -835,7 +835,7 @@ func (m *tuiModel) initEditInputs() {
 	m.editInputs[editName].SetValue(t.Name)
 	m.editInputs[editURL].SetValue(t.URL)
 	m.editInputs[editType].SetValue(t.Type)
-	m.editInputs[editType].Placeholder = "http, tcp, ping, dns, visual"
+	m.editInputs[editType].Placeholder = strings.Join(typeOptions, ", ")
 	m.editInputs[editInterval].SetValue(fmt.Sprintf("%d", t.Interval))
 	m.editInputs[editTimeout].SetValue(fmt.Sprintf("%d", t.Timeout))
 	m.editInputs[editRetries].SetValue(fmt.Sprintf("%d", t.Retries))
@@ -922,9 +922,19 @@ func (m *tuiModel) runCheck(t *db.Target) tea.Cmd {
 	target := *t
 	m.checkingIDs[target.ID] = true
 	m.refreshData()
+	eng := m.engine
 	return func() tea.Msg {
-		result := checker.Check(&target)
-		return checkDoneMsg{targetID: target.ID, result: result}
+		done := make(chan *checker.Result, 1)
+		// The result is saved and notified here rather than in Update, so
+		// slow notification channels don't freeze the UI
+		if !eng.Submit(target, func(t db.Target, r *checker.Result) {
+			recordResult(&t, r)
+			done <- r
+		}) {
+			// Already being checked; that check will report back
+			return nil
+		}
+		return checkDoneMsg{targetID: target.ID, result: <-done}
 	}
 }
 
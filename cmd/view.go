@@ -2,8 +2,11 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
+	"github.com/naru-bot/upp/internal/checker"
+	"github.com/naru-bot/upp/internal/config"
 	"github.com/naru-bot/upp/internal/db"
 	"github.com/spf13/cobra"
 )
@@ -26,9 +29,11 @@ Examples:
 }
 
 type viewOutput struct {
-	Target    db.Target       `json:"target"`
-	LastCheck *db.CheckResult `json:"last_check,omitempty"`
-	Snapshot  *db.Snapshot    `json:"snapshot,omitempty"`
+	Target    db.Target        `json:"target"`
+	LastCheck *db.CheckResult  `json:"last_check,omitempty"`
+	Alert     *db.AlertState   `json:"alert,omitempty"`
+	Snapshot  *db.Snapshot     `json:"snapshot,omitempty"`
+	Notifies  []reachedChannel `json:"notifies"`
 }
 
 func runView(cmd *cobra.Command, args []string) {
@@ -42,6 +47,11 @@ func runView(cmd *cobra.Command, args []string) {
 		lastCheck = &checks[0]
 	}
 
+	var alertState *db.AlertState
+	if lastCheck != nil {
+		alertState, _ = db.GetAlertState(t.ID)
+	}
+
 	includeData, _ := cmd.Flags().GetBool("data")
 	var snapshot *db.Snapshot
 	if includeData {
@@ -50,32 +60,104 @@ func runView(cmd *cobra.Command, args []string) {
 		}
 	}
 
+	channels, err := reachedChannels(t)
+	if err != nil {
+		exitError(err.Error())
+	}
+
 	if jsonOutput {
-		printJSON(viewOutput{Target: *t, LastCheck: lastCheck, Snapshot: snapshot})
+		printJSON(viewOutput{Target: *t, LastCheck: lastCheck, Alert: alertState, Snapshot: snapshot, Notifies: channels})
 		return
 	}
 
 	fmt.Printf("Target: %s (id %d)\n", t.Name, t.ID)
-	fmt.Printf("URL: %s\n", t.URL)
+	if t.Type == "heartbeat" {
+		fmt.Printf("Ping URL: %s\n", config.Get().PingURL(checker.HeartbeatToken(t)))
+	} else {
+		fmt.Printf("URL: %s\n", t.URL)
+	}
 	fmt.Printf("Type: %s\n", t.Type)
 	fmt.Printf("Interval: %ds\n", t.Interval)
 	fmt.Printf("Timeout: %ds\n", t.Timeout)
 	fmt.Printf("Retries: %d\n", t.Retries)
 	fmt.Printf("Paused: %v\n", t.Paused)
+	if t.NextRunAt != nil && !t.Paused {
+		fmt.Printf("Next check: %s (%s)\n", t.NextRunAt.Format(time.RFC3339), formatNextRun(t))
+	}
 	fmt.Printf("Created: %s\n", t.CreatedAt.Format(time.RFC3339))
 
 	if t.Selector != "" {
-		fmt.Printf("Selector: %s\n", t.Selector)
+		fmt.Printf("%s: %s\n", selectorLabel(t), t.Selector)
+	}
+	if t.Resolver != "" {
+		fmt.Printf("Resolver: %s\n", t.Resolver)
+	}
+	if t.Steps != "" {
+		if steps, err := checker.ParseSteps(t.Steps); err == nil {
+			fmt.Printf("Steps:\n")
+			for i, s := range steps {
+				method := s.Method
+				if method == "" {
+					method = "GET"
+				}
+				fmt.Printf("  %d. %s %s", i+1, strings.ToUpper(method), s.URL)
+				if s.Name != "" {
+					fmt.Printf(" (%s)", s.Name)
+				}
+				fmt.Println()
+			}
+		}
 	}
 	if t.Headers != "" {
 		fmt.Printf("Headers: %s\n", t.Headers)
 	}
+	if proxy := checker.DescribeProxy(t); proxy != "" && usesProxy(t.Type) {
+		fmt.Printf("Proxy: %s\n", proxy)
+	}
+	if t.ClientCert != "" {
+		fmt.Printf("Client cert: %s\n", describeCertRef(t.ClientCert))
+		fmt.Printf("Client key: %s\n", describeCertRef(t.ClientKey))
+	}
+	if t.CACert != "" {
+		fmt.Printf("CA bundle: %s\n", describeCertRef(t.CACert))
+	}
 	if t.Expect != "" {
 		fmt.Printf("Expect: %s\n", t.Expect)
 	}
+	if t.Render != "" {
+		fmt.Printf("Render: %s\n", t.Render)
+	}
+	if t.Numeric != "" {
+		fmt.Printf("Numeric: %s\n", t.Numeric)
+	}
+	if t.WatchHeaders != "" {
+		fmt.Printf("Watched headers: %s\n", t.WatchHeaders)
+	}
+	if rules, err := checker.ParseNormalize(t.Normalize); err == nil && !rules.IsZero() {
+		fmt.Printf("Normalize: %s\n", strings.Join(describeNormalize(rules), ", "))
+	}
+	if assertions, err := checker.ParseAssertions(t.Assertions); err == nil && len(assertions) > 0 {
+		fmt.Printf("Assertions:\n")
+		for _, a := range assertions {
+			fmt.Printf("  - %s\n", a.Raw)
+		}
+	}
+	if t.Packets > 0 {
+		fmt.Printf("Packets: %d\n", t.Packets)
+	}
+	if t.DegradedLoss > 0 || t.MaxLoss > 0 {
+		fmt.Printf("Loss thresholds: %s\n", formatLossThresholds(t))
+	}
+	if t.ExpiryDays > 0 {
+		fmt.Printf("Expiry days: %d\n", t.ExpiryDays)
+	}
+	if t.Type == "heartbeat" {
+		fmt.Printf("Grace: %s\n", checker.HeartbeatGrace(t))
+	}
 	if t.Threshold > 0 {
 		fmt.Printf("Threshold: %.1f%%\n", t.Threshold)
 	}
+	fmt.Printf("Notifies: %s\n", describeReachedChannels(channels))
 
 	if lastCheck == nil {
 		fmt.Println("Last check: none (run 'upp check')")
@@ -93,9 +175,19 @@ func runView(cmd *cobra.Command, args []string) {
 	if lastCheck.ResponseTime != 0 {
 		fmt.Printf("Response time: %dms\n", lastCheck.ResponseTime)
 	}
+	if lastCheck.Timing != nil {
+		fmt.Printf("Timing: %s\n", formatTiming(lastCheck.Timing))
+	}
 	if lastCheck.Error != "" {
 		fmt.Printf("Error: %s\n", lastCheck.Error)
 	}
+	if alertState != nil {
+		fmt.Printf("Alert state: %s since %s", alertState.State, alertState.Since.Format(time.RFC3339))
+		if alertState.Failures > 0 {
+			fmt.Printf(" (%d consecutive failures)", alertState.Failures)
+		}
+		fmt.Println()
+	}
 
 	if includeData {
 		if snapshot == nil {
@@ -109,3 +201,32 @@ func runView(cmd *cobra.Command, args []string) {
 		}
 	}
 }
+
+// usesProxy reports whether checks of this type go through a proxy.
+func usesProxy(typ string) bool {
+	switch typ {
+	case "http", "https", "transaction", "feed", "sitemap", "security", "visual", "":
+		return true
+	}
+	return false
+}
+
+// describeReachedChannels renders the channels a target notifies, e.g.
+// "oncall (down, error), personal (all events)".
+func describeReachedChannels(channels []reachedChannel) string {
+	if len(channels) == 0 {
+		return "none"
+	}
+	parts := make([]string, len(channels))
+	for i, c := range channels {
+		events := "all events"
+		if len(c.Events) > 0 {
+			events = strings.Join(c.Events, ", ")
+		}
+		if !c.Enabled {
+			events += ", disabled"
+		}
+		parts[i] = fmt.Sprintf("%s (%s)", c.Channel, events)
+	}
+	return strings.Join(parts, ", ")
+}
@@ -161,6 +161,8 @@ func renderDashboard() {
 			statusStr = colorGreen("● " + status)
 		case "changed":
 			statusStr = colorYellow("△ changed")
+		case "degraded":
+			statusStr = colorYellow("⚠ degraded")
 		case "down", "error":
 			statusStr = colorRed("✗ " + status)
 			if len(lastResults) > 0 && lastResults[0].Error != "" {
@@ -0,0 +1,99 @@
+package alert
+
+import (
+	"time"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// Event types produced by Evaluate.
+const (
+	EventDown     = "down"
+	EventDegraded = "degraded"
+	EventRecovery = "recovery"
+	EventReminder = "reminder"
+	EventChanged  = "changed"
+
+	// EventSSLExpiring is produced by EvaluateSSL rather than Evaluate.
+	EventSSLExpiring = "ssl_expiring"
+)
+
+// Policy controls when a failing target turns into an alert.
+type Policy struct {
+	FailureThreshold int           // consecutive failures before alerting
+	ReminderInterval time.Duration // re-alert interval while down (0 = never)
+}
+
+// stateFor maps a check status onto an alert state.
+func stateFor(status string) string {
+	switch status {
+	case "down", "error":
+		return "down"
+	case "degraded":
+		return "degraded"
+	default:
+		return "up"
+	}
+}
+
+// Evaluate advances the alert state machine with the status of the latest
+// check and returns the events that should be notified, if any. The state is
+// updated in place; the caller is responsible for persisting it.
+func Evaluate(s *db.AlertState, status string, now time.Time, p Policy) []string {
+	threshold := p.FailureThreshold
+	if threshold <= 0 {
+		threshold = 1
+	}
+
+	var events []string
+	observed := stateFor(status)
+
+	if observed == "up" {
+		s.Failures = 0
+		if s.State != "up" {
+			events = append(events, EventRecovery)
+			s.State = "up"
+			s.Since = now
+			s.LastNotified = &now
+		}
+		if status == "changed" {
+			events = append(events, EventChanged)
+		}
+		return events
+	}
+
+	s.Failures++
+	if s.Failures < threshold {
+		return nil
+	}
+
+	if s.State != observed {
+		s.State = observed
+		s.Since = now
+		s.LastNotified = &now
+		if observed == "down" {
+			return []string{EventDown}
+		}
+		return []string{EventDegraded}
+	}
+
+	if p.ReminderInterval > 0 && (s.LastNotified == nil || now.Sub(*s.LastNotified) >= p.ReminderInterval) {
+		s.LastNotified = &now
+		return []string{EventReminder}
+	}
+	return nil
+}
+
+// EvaluateSSL reports whether a certificate expiring at expiry should be
+// notified as expiring: once per certificate, when fewer than warnDays days
+// are left. The state is updated in place like Evaluate does.
+func EvaluateSSL(s *db.AlertState, expiry, now time.Time, warnDays int) bool {
+	if expiry.Sub(now) >= time.Duration(warnDays)*24*time.Hour {
+		return false
+	}
+	if s.SSLNotified != nil && s.SSLNotified.Equal(expiry) {
+		return false
+	}
+	s.SSLNotified = &expiry
+	return true
+}
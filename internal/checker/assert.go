@@ -0,0 +1,429 @@
+package checker
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/itchyny/gojq"
+)
+
+// Assertion is a parsed response assertion of the form
+// "<subject> [arg] <op> [value]", for example:
+//
+//	status in 200-299
+//	header Content-Type contains json
+//	jq .queue.depth < 1000
+//	body !contains "Exception"
+//	response_time < 800ms
+//	body_size > 1KB
+type Assertion struct {
+	Raw     string
+	Subject string // status, header, jq, body, response_time or body_size
+	Arg     string // header name or jq filter
+	Op      string
+	Value   string
+
+	query  *gojq.Query
+	re     *regexp.Regexp
+	number float64 // Value as a number (milliseconds for response_time, bytes for body_size)
+}
+
+var (
+	numericOps = []string{"==", "!=", "<", "<=", ">", ">="}
+	stringOps  = []string{"==", "!=", "contains", "!contains", "matches", "!matches"}
+	valueOps   = []string{"==", "!=", "<", "<=", ">", ">=", "contains", "!contains", "matches", "!matches", "exists", "!exists"}
+
+	// assertionOps lists the operators each subject accepts.
+	assertionOps = map[string][]string{
+		"status":        {"in", "!in", "==", "!=", "<", "<=", ">", ">="},
+		"header":        valueOps,
+		"jq":            valueOps,
+		"body":          stringOps,
+		"response_time": numericOps,
+		"body_size":     numericOps,
+	}
+)
+
+// assertionToken is a word of an assertion; quoted words keep their
+// position in the raw string so multi-word jq filters can be recovered.
+type assertionToken struct {
+	text       string
+	start, end int
+	quoted     bool
+}
+
+// ParseAssertion parses and validates a single assertion.
+func ParseAssertion(s string) (*Assertion, error) {
+	raw := strings.TrimSpace(s)
+	tokens, err := tokenizeAssertion(raw)
+	if err != nil {
+		return nil, fmt.Errorf("assertion %q: %w", raw, err)
+	}
+	if len(tokens) < 2 {
+		return nil, fmt.Errorf("assertion %q: expected <subject> <op> <value>", raw)
+	}
+
+	a := &Assertion{Raw: raw, Subject: strings.ToLower(tokens[0].text)}
+	ops, ok := assertionOps[a.Subject]
+	if !ok {
+		return nil, fmt.Errorf("assertion %q: unknown subject %q (use status, header, jq, body, response_time or body_size)", raw, tokens[0].text)
+	}
+
+	// Find the operator. Header and jq assertions take an argument first;
+	// a jq filter may itself contain spaces and comparison operators, so
+	// the last unquoted operator word is the one that counts.
+	opIdx := -1
+	switch a.Subject {
+	case "header":
+		if len(tokens) >= 3 {
+			opIdx = 2
+		}
+	case "jq":
+		for i := len(tokens) - 1; i >= 2; i-- {
+			if !tokens[i].quoted && isAssertionOp(tokens[i].text) {
+				opIdx = i
+				break
+			}
+		}
+	default:
+		opIdx = 1
+	}
+	if opIdx < 0 {
+		return nil, fmt.Errorf("assertion %q: missing operator", raw)
+	}
+	if opIdx > 1 {
+		a.Arg = strings.TrimSpace(raw[tokens[1].start:tokens[opIdx-1].end])
+		if tokens[1].quoted && opIdx == 2 {
+			a.Arg = tokens[1].text
+		}
+	}
+
+	a.Op = strings.ToLower(tokens[opIdx].text)
+	if !slices.Contains(ops, a.Op) {
+		return nil, fmt.Errorf("assertion %q: %s does not support %q (use %s)", raw, a.Subject, tokens[opIdx].text, strings.Join(ops, ", "))
+	}
+
+	rest := tokens[opIdx+1:]
+	switch {
+	case len(rest) == 1 && rest[0].quoted:
+		a.Value = rest[0].text
+	case len(rest) > 0:
+		a.Value = strings.TrimSpace(raw[rest[0].start:])
+	}
+	if strings.HasSuffix(a.Op, "exists") {
+		if len(rest) > 0 {
+			return nil, fmt.Errorf("assertion %q: %s takes no value", raw, a.Op)
+		}
+	} else if len(rest) == 0 {
+		return nil, fmt.Errorf("assertion %q: missing value", raw)
+	}
+
+	if err := a.compile(); err != nil {
+		return nil, fmt.Errorf("assertion %q: %w", raw, err)
+	}
+	return a, nil
+}
+
+// compile parses the argument and value into the forms evaluate needs.
+func (a *Assertion) compile() error {
+	var err error
+	if a.Subject == "jq" {
+		if a.query, err = gojq.Parse(a.Arg); err != nil {
+			return fmt.Errorf("invalid jq filter: %w", err)
+		}
+	}
+	if a.Subject == "header" && a.Arg == "" {
+		return fmt.Errorf("missing header name")
+	}
+
+	switch a.Op {
+	case "matches", "!matches":
+		if a.re, err = regexp.Compile(a.Value); err != nil {
+			return fmt.Errorf("invalid regex: %w", err)
+		}
+		return nil
+	case "in", "!in":
+		for _, part := range strings.Split(a.Value, ",") {
+			for _, code := range strings.SplitN(part, "-", 2) {
+				if _, err := strconv.Atoi(strings.TrimSpace(code)); err != nil {
+					return fmt.Errorf("invalid status range %q", a.Value)
+				}
+			}
+		}
+		return nil
+	}
+
+	switch a.Subject {
+	case "status":
+		n, err := strconv.Atoi(a.Value)
+		if err != nil {
+			return fmt.Errorf("invalid status code %q", a.Value)
+		}
+		a.number = float64(n)
+	case "response_time":
+		if a.number, err = parseMilliseconds(a.Value); err != nil {
+			return err
+		}
+	case "body_size":
+		if a.number, err = parseSize(a.Value); err != nil {
+			return err
+		}
+	default:
+		if isOrderingOp(a.Op) {
+			if a.number, err = strconv.ParseFloat(a.Value, 64); err != nil {
+				return fmt.Errorf("%s needs a number, got %q", a.Op, a.Value)
+			}
+		}
+	}
+	return nil
+}
+
+// ParseAssertions decodes and parses the assertions stored with a target.
+func ParseAssertions(data string) ([]*Assertion, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var list []string
+	if err := json.Unmarshal([]byte(data), &list); err != nil {
+		return nil, fmt.Errorf("invalid assertions: %w", err)
+	}
+	var out []*Assertion
+	for _, s := range list {
+		a, err := ParseAssertion(s)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, a)
+	}
+	return out, nil
+}
+
+func hasStatusAssertion(assertions []*Assertion) bool {
+	for _, a := range assertions {
+		if a.Subject == "status" {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluate checks the assertion against a response and returns a
+// description of the failure, or "" if it holds.
+func (a *Assertion) evaluate(resp *http.Response, body []byte, elapsed time.Duration) string {
+	switch a.Subject {
+	case "status":
+		if a.Op == "in" || a.Op == "!in" {
+			in := isAcceptedStatus(resp.StatusCode, a.Value)
+			if in == (a.Op == "in") {
+				return ""
+			}
+			return a.failure(strconv.Itoa(resp.StatusCode))
+		}
+		return a.compareNumber(float64(resp.StatusCode), strconv.Itoa(resp.StatusCode))
+	case "response_time":
+		ms := float64(elapsed.Milliseconds())
+		return a.compareNumber(ms, fmt.Sprintf("%dms", elapsed.Milliseconds()))
+	case "body_size":
+		return a.compareNumber(float64(len(body)), fmt.Sprintf("%d bytes", len(body)))
+	case "body":
+		if a.compareString(string(body)) {
+			return ""
+		}
+		return a.failure("")
+	case "header":
+		values, present := resp.Header[http.CanonicalHeaderKey(a.Arg)]
+		return a.compareValue(present, strings.Join(values, ", "))
+	case "jq":
+		var data interface{}
+		if err := json.Unmarshal(body, &data); err != nil {
+			return a.Raw + ": response is not valid JSON"
+		}
+		v, ok := a.query.Run(data).Next()
+		if err, isErr := v.(error); ok && isErr {
+			return a.Raw + ": jq error: " + err.Error()
+		}
+		present := ok && v != nil
+		actual := ""
+		if present {
+			if s, isStr := v.(string); isStr {
+				actual = s
+			} else {
+				b, _ := json.Marshal(v)
+				actual = string(b)
+			}
+		}
+		return a.compareValue(present, actual)
+	}
+	return a.Raw + ": unknown subject"
+}
+
+// compareValue evaluates an operator against a header or jq value that may
+// be absent.
+func (a *Assertion) compareValue(present bool, actual string) string {
+	switch a.Op {
+	case "exists", "!exists":
+		if present == (a.Op == "exists") {
+			return ""
+		}
+		if present {
+			return a.failure(actual)
+		}
+		return a.failure("missing")
+	}
+	if !present {
+		return a.failure("missing")
+	}
+	if isOrderingOp(a.Op) {
+		n, err := strconv.ParseFloat(actual, 64)
+		if err != nil {
+			return a.failure(actual + ", not a number")
+		}
+		return a.compareNumber(n, actual)
+	}
+	// Numeric equality ignores formatting, so 1.0 == 1
+	if a.Op == "==" || a.Op == "!=" {
+		if x, err := strconv.ParseFloat(actual, 64); err == nil {
+			if y, err := strconv.ParseFloat(a.Value, 64); err == nil {
+				if (x == y) == (a.Op == "==") {
+					return ""
+				}
+				return a.failure(actual)
+			}
+		}
+	}
+	if a.compareString(actual) {
+		return ""
+	}
+	return a.failure(actual)
+}
+
+func (a *Assertion) compareString(actual string) bool {
+	switch a.Op {
+	case "==":
+		return actual == a.Value
+	case "!=":
+		return actual != a.Value
+	case "contains":
+		return strings.Contains(actual, a.Value)
+	case "!contains":
+		return !strings.Contains(actual, a.Value)
+	case "matches":
+		return a.re.MatchString(actual)
+	case "!matches":
+		return !a.re.MatchString(actual)
+	}
+	return false
+}
+
+func (a *Assertion) compareNumber(actual float64, shown string) string {
+	var ok bool
+	switch a.Op {
+	case "==":
+		ok = actual == a.number
+	case "!=":
+		ok = actual != a.number
+	case "<":
+		ok = actual < a.number
+	case "<=":
+		ok = actual <= a.number
+	case ">":
+		ok = actual > a.number
+	case ">=":
+		ok = actual >= a.number
+	}
+	if ok {
+		return ""
+	}
+	return a.failure(shown)
+}
+
+// failure describes a failed assertion along with the actual value, which is
+// left out for body assertions.
+func (a *Assertion) failure(actual string) string {
+	if actual == "" {
+		return a.Raw
+	}
+	if len(actual) > 80 {
+		actual = actual[:77] + "..."
+	}
+	return fmt.Sprintf("%s (got %s)", a.Raw, actual)
+}
+
+// tokenizeAssertion splits an assertion into words; single or double quotes
+// group a word containing spaces.
+func tokenizeAssertion(s string) ([]assertionToken, error) {
+	var tokens []assertionToken
+	i := 0
+	for i < len(s) {
+		if s[i] == ' ' || s[i] == '\t' {
+			i++
+			continue
+		}
+		start := i
+		if q := s[i]; q == '"' || q == '\'' {
+			end := strings.IndexByte(s[i+1:], q)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated quote")
+			}
+			i += end + 2
+			tokens = append(tokens, assertionToken{text: s[start+1 : i-1], start: start, end: i, quoted: true})
+			continue
+		}
+		for i < len(s) && s[i] != ' ' && s[i] != '\t' {
+			i++
+		}
+		tokens = append(tokens, assertionToken{text: s[start:i], start: start, end: i})
+	}
+	return tokens, nil
+}
+
+func isAssertionOp(s string) bool {
+	s = strings.ToLower(s)
+	return slices.Contains(valueOps, s)
+}
+
+func isOrderingOp(op string) bool {
+	return op == "<" || op == "<=" || op == ">" || op == ">="
+}
+
+// parseMilliseconds parses a duration such as "800ms" or "2s"; a bare
+// number is taken as milliseconds.
+func parseMilliseconds(s string) (float64, error) {
+	if n, err := strconv.ParseFloat(s, 64); err == nil {
+		return n, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return float64(d.Milliseconds()), nil
+}
+
+// parseSize parses a byte size such as "512", "1KB" or "2.5MB" (powers of
+// 1024).
+func parseSize(s string) (float64, error) {
+	upper := strings.ToUpper(strings.TrimSpace(s))
+	units := []struct {
+		suffix string
+		mult   float64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"K", 1 << 10}, {"M", 1 << 20}, {"B", 1}}
+	mult := 1.0
+	for _, u := range units {
+		if strings.HasSuffix(upper, u.suffix) {
+			upper = strings.TrimSpace(strings.TrimSuffix(upper, u.suffix))
+			mult = u.mult
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(upper, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * mult, nil
+}
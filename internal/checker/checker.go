@@ -1,8 +1,10 @@
 package checker
 
 import (
+	"context"
 	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"image/color"
@@ -10,6 +12,7 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptrace"
 	"net/url"
 	"os"
 	"os/user"
@@ -104,61 +107,112 @@ type Result struct {
 	ResponseTime time.Duration
 	ContentHash  string
 	Content      string
+	RawContent       string // Response body when Content was rendered from HTML
 	Error        string
 	SSLExpiry    *time.Time
 	BodyMatch    *bool   // nil if no expect keyword, true/false otherwise
 	DiffPercent  float64 // Visual diff percentage (for visual checks)
+	Timing           *Timing         // HTTP phase timings (nil for other check types)
+	Ping             *PingStats      // ICMP round-trip statistics (ping checks only)
+	Steps            []StepResult    // Per-step outcome (transaction checks only)
+	FailedAssertions []string        // Every response assertion that did not hold (HTTP checks only)
+	FeedItems        []db.FeedItem   // Every item in the feed (feed checks only)
+	NewItems         []db.FeedItem   // Items not seen by earlier checks (feed checks only)
+	Sitemap          *SitemapDiff    // Pages added, removed or updated since the last snapshot (sitemap checks only)
+	PingBody         string          // Request body of the ping (heartbeat pings only)
+	Value            *float64        // Number parsed from the extracted content (numeric targets only)
+	Headers          string          // Watched response headers, one "Name: value" per line (HTTP checks only)
+	HeaderHash       string          // Hash of Headers, empty when headers are not watched
+	HeaderChanges    []HeaderChange  // Watched headers that changed since the last snapshot
+	Security         *SecurityReport // Score, grade and findings (security checks only)
+	Canceled         bool            // Aborted before it finished; says nothing about the target
 }
 
+// retryDelay is how long to wait between attempts of a failing check.
+const retryDelay = 2 * time.Second
+
+// Check runs a check without cancellation. See CheckContext.
 func Check(target *db.Target) *Result {
+	return CheckContext(context.Background(), target)
+}
+
+// CheckContext runs a check for the target, retrying failures up to the
+// target's retry count. Cancelling ctx aborts the in-flight attempt and any
+// pending retry wait; each attempt is additionally bounded by the target's
+// timeout.
+func CheckContext(ctx context.Context, target *db.Target) *Result {
 	retries := target.Retries
-	if retries <= 0 {
+	// A heartbeat's state only changes when a ping arrives
+	if retries <= 0 || target.Type == "heartbeat" {
 		retries = 1
 	}
 
 	var result *Result
 	for i := 0; i < retries; i++ {
-		result = checkOnce(target)
+		result = checkOnce(ctx, target)
 		if result.Status == "up" || result.Status == "unchanged" || result.Status == "changed" {
 			return result
 		}
 		if i < retries-1 {
-			time.Sleep(2 * time.Second) // wait between retries
+			select {
+			case <-ctx.Done():
+				return result
+			case <-time.After(retryDelay):
+			}
 		}
 	}
 	return result
 }
 
-func checkOnce(target *db.Target) *Result {
+func checkOnce(ctx context.Context, target *db.Target) *Result {
+	if err := ctx.Err(); err != nil {
+		return &Result{Status: "error", Error: "check canceled: " + err.Error(), Canceled: true}
+	}
 	switch target.Type {
 	case "http", "https":
-		return checkHTTP(target)
+		return checkHTTP(ctx, target)
 	case "tcp":
-		return checkTCP(target)
+		return checkTCP(ctx, target)
 	case "ping":
-		return checkPing(target)
+		return checkPing(ctx, target)
 	case "dns":
-		return checkDNS(target)
+		return checkDNS(ctx, target)
+	case "tls":
+		return checkTLS(ctx, target)
+	case "transaction":
+		return checkTransaction(ctx, target)
+	case "feed":
+		return checkFeed(ctx, target)
+	case "sitemap":
+		return checkSitemap(ctx, target)
+	case "heartbeat":
+		return checkHeartbeat(ctx, target)
+	case "security":
+		return checkSecurity(ctx, target)
 	case "visual":
-		return checkVisual(target)
+		return checkVisual(ctx, target)
 	case "whois":
-		return checkWhois(target)
+		return checkWhois(ctx, target)
 	default:
-		return checkHTTP(target)
+		return checkHTTP(ctx, target)
 	}
 }
 
-func checkHTTP(target *db.Target) *Result {
-	start := time.Now()
-	result := &Result{}
-
-	timeout := time.Duration(target.Timeout) * time.Second
-	if timeout <= 0 {
-		timeout = 30 * time.Second
+// newHTTPClient returns a client configured with the target's TLS, proxy
+// and redirect options.
+func newHTTPClient(target *db.Target, timeout time.Duration) (*http.Client, error) {
+	tlsConfig, err := targetTLSConfig(target)
+	if err != nil {
+		return nil, err
 	}
-
+	// Bound each connection phase as well as the request as a whole, so a
+	// stalled handshake is reported as such rather than as a generic timeout.
 	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: target.Insecure},
+		DialContext:           (&net.Dialer{Timeout: timeout}).DialContext,
+		TLSHandshakeTimeout:   timeout,
+		ResponseHeaderTimeout: timeout,
+		TLSClientConfig:       tlsConfig,
+		Proxy:                 ProxyFunc(target),
 	}
 	client := &http.Client{
 		Timeout:   timeout,
@@ -169,6 +223,37 @@ func checkHTTP(target *db.Target) *Result {
 			return http.ErrUseLastResponse
 		}
 	}
+	return client, nil
+}
+
+func checkHTTP(ctx context.Context, target *db.Target) *Result {
+	start := time.Now()
+	result := &Result{}
+
+	timeout := time.Duration(target.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	client, err := newHTTPClient(target, timeout)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+	assertions, err := ParseAssertions(target.Assertions)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+	rules, err := targetNormalize(target)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
 
 	method := strings.ToUpper(target.Method)
 	if method == "" {
@@ -178,7 +263,9 @@ func checkHTTP(target *db.Target) *Result {
 	if target.Body != "" {
 		bodyReader = strings.NewReader(target.Body)
 	}
-	req, err := http.NewRequest(method, target.URL, bodyReader)
+	trace := newTimingTrace(start)
+	ctx = httptrace.WithClientTrace(ctx, trace.clientTrace())
+	req, err := http.NewRequestWithContext(ctx, method, target.URL, bodyReader)
 	if err != nil {
 		result.Status = "error"
 		result.Error = err.Error()
@@ -216,22 +303,46 @@ func checkHTTP(target *db.Target) *Result {
 		result.SSLExpiry = &expiry
 	}
 
+	if target.WatchHeaders != "" {
+		result.Headers, result.HeaderHash = captureHeaders(resp.Header, target.WatchHeaders)
+	}
+
+	bodyStart := time.Now()
 	body, err := io.ReadAll(resp.Body)
+	result.ResponseTime = time.Since(start)
+	result.Timing = trace.result(time.Since(bodyStart))
 	if err != nil {
 		result.Status = "error"
 		result.Error = "failed to read body: " + err.Error()
 		return result
 	}
 
-	// Apply jq filter if set (for JSON API monitoring)
+	// Apply jq filter if set (for JSON API monitoring). Paths listed in
+	// the jq_delete normalization rules are removed first.
 	content := string(body)
-	if target.JQFilter != "" {
-		var jsonData interface{}
-		if err := json.Unmarshal(body, &jsonData); err != nil {
+	var jsonData interface{}
+	isJSON := false
+	if target.JQFilter != "" || len(rules.JQDelete) > 0 {
+		if err := json.Unmarshal(body, &jsonData); err == nil {
+			isJSON = true
+		} else if target.JQFilter != "" {
 			result.Status = "error"
 			result.Error = "response is not valid JSON: " + err.Error()
 			return result
 		}
+	}
+	if isJSON && len(rules.JQDelete) > 0 {
+		if jsonData, err = deleteJSONPaths(jsonData, rules.JQDelete); err != nil {
+			result.Status = "error"
+			result.Error = err.Error()
+			return result
+		}
+		if target.JQFilter == "" {
+			b, _ := json.MarshalIndent(jsonData, "", "  ")
+			content = string(b)
+		}
+	}
+	if target.JQFilter != "" {
 		query, err := gojq.Parse(target.JQFilter)
 		if err != nil {
 			result.Status = "error"
@@ -261,10 +372,17 @@ func checkHTTP(target *db.Target) *Result {
 		content = strings.Join(filtered, "\n")
 	}
 
-	// Extract content based on selector (for HTML pages)
-	if target.JQFilter == "" && target.Selector != "" {
+	// Extract content based on selector (for HTML pages), after dropping
+	// the elements listed in the drop_selectors normalization rules
+	if !isJSON && (target.Selector != "" || len(rules.DropSelectors) > 0) {
 		doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
 		if err == nil {
+			dropSelectors(doc, rules.DropSelectors)
+			if target.Selector == "" {
+				content, _ = doc.Html()
+			}
+		}
+		if err == nil && target.Selector != "" {
 			var selected []string
 			doc.Find(target.Selector).Each(func(i int, s *goquery.Selection) {
 				// Strip style/script so CSS/JS doesn't pollute extracted text.
@@ -277,47 +395,81 @@ func checkHTTP(target *db.Target) *Result {
 		}
 	}
 
+	// The expected keyword is looked for before rendering, as rendering
+	// drops the title, markup and scripts it may be part of
+	expectIn := content
+
+	// Without a selector or jq filter, render HTML as readable text (or
+	// Markdown) so snapshots and diffs show what a visitor would see
+	if target.Selector == "" && target.JQFilter == "" && !isJSON && target.Render != RenderRaw && IsHTML(resp.Header.Get("Content-Type"), body) {
+		result.RawContent = string(body)
+		content = RenderHTML(content, target.Render)
+	}
+
 	result.Content = content
-	// Strip dynamic tokens (CSRF, nonces, etc.) before hashing
-	// so that only meaningful content changes are detected
-	normalized := stripDynamicContent(content)
+	// Strip dynamic tokens (CSRF, nonces, etc.) and apply the normalization
+	// rules before hashing so that only meaningful content changes are detected
+	normalized := normalizeContent(content, rules)
 	hash := sha256.Sum256([]byte(normalized))
 	result.ContentHash = fmt.Sprintf("%x", hash)
 
 	// Check expected keyword
 	if target.Expect != "" {
-		matched := strings.Contains(content, target.Expect)
+		matched := strings.Contains(expectIn, target.Expect)
 		result.BodyMatch = &matched
 	}
 
-	// Determine status
-	if isAcceptedStatus(resp.StatusCode, target.AcceptStatus) {
-		// Check keyword match
-		if result.BodyMatch != nil && !*result.BodyMatch {
-			result.Status = "down"
-			result.Error = fmt.Sprintf("expected keyword %q not found", target.Expect)
-			return result
+	// Determine status. A status assertion replaces the default 2xx/3xx
+	// rule unless accepted statuses were set explicitly.
+	var failures []string
+	statusOK := isAcceptedStatus(resp.StatusCode, target.AcceptStatus)
+	if !statusOK && target.AcceptStatus == "" && hasStatusAssertion(assertions) {
+		statusOK = true
+	}
+	if !statusOK {
+		failures = append(failures, fmt.Sprintf("HTTP %d", resp.StatusCode))
+	}
+	if result.BodyMatch != nil && !*result.BodyMatch {
+		failures = append(failures, fmt.Sprintf("expected keyword %q not found", target.Expect))
+	}
+	// Evaluate every assertion against the raw body so all failures are
+	// reported, not just the first
+	for _, a := range assertions {
+		if msg := a.evaluate(resp, body, result.ResponseTime); msg != "" {
+			result.FailedAssertions = append(result.FailedAssertions, msg)
 		}
+	}
+	failures = append(failures, result.FailedAssertions...)
+	if len(failures) > 0 {
+		result.Status = "down"
+		result.Error = strings.Join(failures, "; ")
+		return result
+	}
+	if err := parseTargetValue(target, result); err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
 
-		snaps, err := db.GetLatestSnapshots(target.ID, 1)
-		if err == nil && len(snaps) > 0 {
-			if snaps[0].Hash != result.ContentHash {
-				result.Status = "changed"
-			} else {
-				result.Status = "unchanged"
-			}
+	snaps, err := db.GetLatestSnapshots(target.ID, 1)
+	if err == nil && len(snaps) > 0 {
+		// Headers are compared once a snapshot has them, so starting to
+		// watch them is not reported as a change
+		if result.HeaderHash != "" && snaps[0].HeaderHash != "" && snaps[0].HeaderHash != result.HeaderHash {
+			result.HeaderChanges = DiffHeaders(snaps[0].Headers, result.Headers)
+		}
+		if snaps[0].Hash != result.ContentHash || len(result.HeaderChanges) > 0 {
+			result.Status = "changed"
 		} else {
-			result.Status = "up"
+			result.Status = "unchanged"
 		}
 	} else {
-		result.Status = "down"
-		result.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		result.Status = "up"
 	}
-
 	return result
 }
 
-func checkTCP(target *db.Target) *Result {
+func checkTCP(ctx context.Context, target *db.Target) *Result {
 	start := time.Now()
 	result := &Result{}
 
@@ -326,7 +478,8 @@ func checkTCP(target *db.Target) *Result {
 		timeout = 10 * time.Second
 	}
 
-	conn, err := net.DialTimeout("tcp", target.URL, timeout)
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", target.URL)
 	result.ResponseTime = time.Since(start)
 
 	if err != nil {
@@ -339,26 +492,20 @@ func checkTCP(target *db.Target) *Result {
 	return result
 }
 
-func checkPing(target *db.Target) *Result {
+func checkDNS(ctx context.Context, target *db.Target) *Result {
+	if target.Selector != "" {
+		return checkDNSRecord(ctx, target)
+	}
 	start := time.Now()
 	result := &Result{}
 
-	cmd := exec.Command("ping", "-c", "1", "-W", "5", target.URL)
-	err := cmd.Run()
-	result.ResponseTime = time.Since(start)
-
-	if err != nil {
-		result.Status = "down"
-		result.Error = "ping failed"
-		return result
+	timeout := time.Duration(target.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
 	}
-	result.Status = "up"
-	return result
-}
-
-func checkDNS(target *db.Target) *Result {
-	start := time.Now()
-	result := &Result{}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	resolver := net.DefaultResolver
 
 	host := target.URL
 	// Strip protocol/path if a full URL was provided
@@ -368,7 +515,7 @@ func checkDNS(target *db.Target) *Result {
 		}
 	}
 
-	addrs, err := net.LookupHost(host)
+	addrs, err := resolver.LookupHost(ctx, host)
 	result.ResponseTime = time.Since(start)
 
 	if err != nil {
@@ -387,21 +534,21 @@ func checkDNS(target *db.Target) *Result {
 	sb.WriteString(fmt.Sprintf("Resolved: %s\n", strings.Join(addrs, ", ")))
 
 	// Also try MX, NS, TXT records
-	if mx, err := net.LookupMX(host); err == nil && len(mx) > 0 {
+	if mx, err := resolver.LookupMX(ctx, host); err == nil && len(mx) > 0 {
 		var mxHosts []string
 		for _, m := range mx {
 			mxHosts = append(mxHosts, fmt.Sprintf("%s (pri %d)", m.Host, m.Pref))
 		}
 		sb.WriteString(fmt.Sprintf("MX: %s\n", strings.Join(mxHosts, ", ")))
 	}
-	if ns, err := net.LookupNS(host); err == nil && len(ns) > 0 {
+	if ns, err := resolver.LookupNS(ctx, host); err == nil && len(ns) > 0 {
 		var nsHosts []string
 		for _, n := range ns {
 			nsHosts = append(nsHosts, n.Host)
 		}
 		sb.WriteString(fmt.Sprintf("NS: %s\n", strings.Join(nsHosts, ", ")))
 	}
-	if txt, err := net.LookupTXT(host); err == nil && len(txt) > 0 {
+	if txt, err := resolver.LookupTXT(ctx, host); err == nil && len(txt) > 0 {
 		sb.WriteString(fmt.Sprintf("TXT: %s\n", strings.Join(txt, "; ")))
 	}
 
@@ -412,6 +559,159 @@ func checkDNS(target *db.Target) *Result {
 	return result
 }
 
+// defaultExpiryDays is how close to expiry a certificate may get before a
+// tls check reports the target down, when the target doesn't set its own.
+const defaultExpiryDays = 14
+
+func checkTLS(ctx context.Context, target *db.Target) *Result {
+	start := time.Now()
+	result := &Result{}
+
+	host, addr, err := tlsAddress(target.URL)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+
+	timeout := time.Duration(target.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Verification is done below rather than during the handshake, so the
+	// certificate is still recorded when the chain or hostname is invalid.
+	// Servers requiring mutual TLS get the target's client certificate.
+	tlsConfig, err := targetTLSConfig(target)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+	roots := tlsConfig.RootCAs
+	tlsConfig.ServerName = host
+	tlsConfig.InsecureSkipVerify = true
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config:    tlsConfig,
+	}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	result.ResponseTime = time.Since(start)
+	if err != nil {
+		result.Status = "down"
+		result.Error = err.Error()
+		return result
+	}
+	state := conn.(*tls.Conn).ConnectionState()
+	conn.Close()
+
+	if len(state.PeerCertificates) == 0 {
+		result.Status = "down"
+		result.Error = "server presented no certificate"
+		return result
+	}
+	leaf := state.PeerCertificates[0]
+	result.SSLExpiry = &leaf.NotAfter
+
+	result.Content = formatCertContent(addr, leaf)
+	hash := sha256.Sum256([]byte(result.Content))
+	result.ContentHash = fmt.Sprintf("%x", hash)
+
+	// Like HTTP checks, --insecure accepts any chain and hostname; the
+	// expiry is still checked
+	if !target.Insecure {
+		intermediates := x509.NewCertPool()
+		for _, c := range state.PeerCertificates[1:] {
+			intermediates.AddCert(c)
+		}
+		_, err = leaf.Verify(x509.VerifyOptions{
+			DNSName:       host,
+			Intermediates: intermediates,
+			Roots:         roots,
+		})
+		if err != nil {
+			result.Status = "down"
+			result.Error = "certificate verification failed: " + err.Error()
+			return result
+		}
+	}
+
+	minDays := target.ExpiryDays
+	if minDays <= 0 {
+		minDays = defaultExpiryDays
+	}
+	daysLeft := int(time.Until(leaf.NotAfter).Hours() / 24)
+	if daysLeft < minDays {
+		result.Status = "down"
+		result.Error = fmt.Sprintf("certificate expires in %d days (minimum %d)", daysLeft, minDays)
+		return result
+	}
+
+	// Compare with previous snapshot so certificate rotation shows up
+	snaps, err := db.GetLatestSnapshots(target.ID, 1)
+	if err == nil && len(snaps) > 0 {
+		if snaps[0].Hash != result.ContentHash {
+			result.Status = "changed"
+		} else {
+			result.Status = "unchanged"
+		}
+	} else {
+		result.Status = "up"
+	}
+	return result
+}
+
+// tlsAddress splits a tls target into the hostname to verify and the
+// host:port to dial. Bare hostnames and https:// URLs default to port 443.
+func tlsAddress(raw string) (host, addr string, err error) {
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid URL: %w", err)
+		}
+		raw = u.Host
+	}
+	host, port, err := net.SplitHostPort(raw)
+	if err != nil {
+		host, port = raw, "443"
+	}
+	if host == "" {
+		return "", "", fmt.Errorf("no host in %q", raw)
+	}
+	return host, net.JoinHostPort(host, port), nil
+}
+
+// formatCertContent describes a certificate for snapshotting. Only fields
+// fixed by the certificate itself are included, so the hash changes exactly
+// when a different certificate is served.
+func formatCertContent(addr string, cert *x509.Certificate) string {
+	var sb strings.Builder
+	fp := sha256.Sum256(cert.Raw)
+	fpHex := make([]string, len(fp))
+	for i, b := range fp {
+		fpHex[i] = fmt.Sprintf("%02X", b)
+	}
+
+	sb.WriteString(fmt.Sprintf("Endpoint: %s\n", addr))
+	sb.WriteString(fmt.Sprintf("Subject: %s\n", cert.Subject.String()))
+	sb.WriteString(fmt.Sprintf("Issuer: %s\n", cert.Issuer.String()))
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	if len(sans) > 0 {
+		sb.WriteString(fmt.Sprintf("SANs: %s\n", strings.Join(sans, ", ")))
+	}
+	sb.WriteString(fmt.Sprintf("Serial: %s\n", cert.SerialNumber.Text(16)))
+	sb.WriteString(fmt.Sprintf("Fingerprint (SHA-256): %s\n", strings.Join(fpHex, ":")))
+	sb.WriteString(fmt.Sprintf("Valid from: %s\n", cert.NotBefore.UTC().Format(time.RFC3339)))
+	sb.WriteString(fmt.Sprintf("Valid until: %s\n", cert.NotAfter.UTC().Format(time.RFC3339)))
+	return sb.String()
+}
+
 // getScreenshotDir returns the directory where screenshots are stored
 func getScreenshotDir() (string, error) {
 	dataDir := filepath.Dir(db.GetDBPath())
@@ -459,13 +759,20 @@ func snapWritableDir() string {
 	return snapDir
 }
 
-// takeScreenshot captures a screenshot of the URL using headless browser
-func takeScreenshot(url, outputPath string, timeout time.Duration) error {
+// takeScreenshot captures a screenshot of the URL using headless browser.
+// The browser is killed if ctx is cancelled or the timeout elapses.
+func takeScreenshot(ctx context.Context, url, outputPath, proxyArg string, timeout time.Duration) error {
 	binary, args := findHeadlessBrowser()
 	if binary == "" {
 		return fmt.Errorf("no headless browser found (run 'upp doctor' for install instructions)")
 	}
 
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
 	// Use a snap-writable temp path for the screenshot, then move it.
 	// Snap-confined Chromium cannot write to arbitrary paths.
 	tmpDir := snapWritableDir()
@@ -478,31 +785,28 @@ func takeScreenshot(url, outputPath string, timeout time.Duration) error {
 		"--hide-scrollbars",
 		"--disable-background-timer-throttling",
 		"--disable-backgrounding-occluded-windows",
-		url,
 	)
+	if proxyArg != "" {
+		cmdArgs = append(cmdArgs, proxyArg)
+	}
+	cmdArgs = append(cmdArgs, url)
 
-	cmd := exec.Command(binary, cmdArgs...)
+	cmd := exec.CommandContext(ctx, binary, cmdArgs...)
 	var stderr strings.Builder
 	cmd.Stderr = &stderr
 
-	// Use context-based timeout for clean cancellation
-	done := make(chan error, 1)
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("failed to start browser: %w", err)
 	}
 
-	go func() { done <- cmd.Wait() }()
-
-	var err error
-	if timeout > 0 {
-		select {
-		case err = <-done:
-		case <-time.After(timeout):
-			cmd.Process.Kill()
-			return fmt.Errorf("screenshot timed out after %v", timeout)
-		}
-	} else {
-		err = <-done
+	err := cmd.Wait()
+	if ctx.Err() == context.DeadlineExceeded {
+		os.Remove(tmpFile)
+		return fmt.Errorf("screenshot timed out after %v", timeout)
+	}
+	if ctx.Err() != nil {
+		os.Remove(tmpFile)
+		return fmt.Errorf("screenshot canceled: %w", ctx.Err())
 	}
 
 	if err != nil {
@@ -584,7 +888,7 @@ func compareImages(img1Path, img2Path string) (float64, error) {
 	return float64(diffPixels) / float64(totalPixels) * 100.0, nil
 }
 
-func checkVisual(target *db.Target) *Result {
+func checkVisual(ctx context.Context, target *db.Target) *Result {
 	start := time.Now()
 	result := &Result{}
 
@@ -615,8 +919,16 @@ func checkVisual(target *db.Target) *Result {
 		os.Rename(currentPath, previousPath)
 	}
 
+	proxyArg, err := browserProxyArg(target)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		result.ResponseTime = time.Since(start)
+		return result
+	}
+
 	// Take new screenshot
-	if err := takeScreenshot(target.URL, currentPath, timeout); err != nil {
+	if err := takeScreenshot(ctx, target.URL, currentPath, proxyArg, timeout); err != nil {
 		result.Status = "error"
 		result.Error = fmt.Sprintf("failed to take screenshot: %v", err)
 		result.ResponseTime = time.Since(start)
@@ -669,7 +981,7 @@ func checkVisual(target *db.Target) *Result {
 	return result
 }
 
-func checkWhois(target *db.Target) *Result {
+func checkWhois(ctx context.Context, target *db.Target) *Result {
 	start := time.Now()
 	result := &Result{}
 
@@ -682,8 +994,17 @@ func checkWhois(target *db.Target) *Result {
 		return result
 	}
 
+	timeout := time.Duration(target.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
 	// Query WHOIS
-	whoisResult, err := whois.Whois(domain)
+	client := whois.NewClient().SetDialer(ctxDialer{ctx}).SetTimeout(timeout)
+	whoisResult, err := client.Whois(domain)
 	if err != nil {
 		result.Status = "error"
 		result.Error = "whois query failed: " + err.Error()
@@ -735,6 +1056,32 @@ func checkWhois(target *db.Target) *Result {
 	return result
 }
 
+// ctxDialer dials with a context and closes the connection when the context
+// is cancelled, so blocking reads in the whois client return promptly.
+type ctxDialer struct {
+	ctx context.Context
+}
+
+func (d ctxDialer) Dial(network, addr string) (net.Conn, error) {
+	conn, err := (&net.Dialer{}).DialContext(d.ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	stop := context.AfterFunc(d.ctx, func() { conn.Close() })
+	return &ctxConn{Conn: conn, stop: stop}, nil
+}
+
+// ctxConn detaches the cancellation hook once the whois client closes it.
+type ctxConn struct {
+	net.Conn
+	stop func() bool
+}
+
+func (c *ctxConn) Close() error {
+	c.stop()
+	return c.Conn.Close()
+}
+
 // extractDomain extracts the registrable domain from a URL
 func extractDomain(rawURL string) (string, error) {
 	// Add protocol if missing
@@ -0,0 +1,40 @@
+package checker
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+func TestCheckTLSInsecure(t *testing.T) {
+	if err := db.InitWithPath(filepath.Join(t.TempDir(), "upp.db")); err != nil {
+		t.Fatal(err)
+	}
+	// httptest serves a certificate no system trusts. The check hangs up
+	// right after the handshake, which the server would log.
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	srv.StartTLS()
+	defer srv.Close()
+	addr := srv.Listener.Addr().String()
+
+	result := checkTLS(context.Background(), &db.Target{ID: 1, Type: "tls", URL: addr})
+	if result.Status != "down" || !strings.Contains(result.Error, "certificate verification failed") {
+		t.Errorf("untrusted certificate: status %q, error %q; want down on verification", result.Status, result.Error)
+	}
+
+	result = checkTLS(context.Background(), &db.Target{ID: 2, Type: "tls", URL: addr, Insecure: true})
+	if result.Status != "up" {
+		t.Errorf("untrusted certificate with --insecure: status %q, error %q; want up", result.Status, result.Error)
+	}
+	if result.SSLExpiry == nil {
+		t.Error("certificate expiry not recorded")
+	}
+}
@@ -0,0 +1,329 @@
+package checker
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/naru-bot/upp/internal/db"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// typeCAA is not defined by dnsmessage; its answers arrive as UnknownResource.
+const typeCAA dnsmessage.Type = 257
+
+// dnsRecordTypes maps the record types a dns target may name to query types.
+var dnsRecordTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"TXT":   dnsmessage.TypeTXT,
+	"NS":    dnsmessage.TypeNS,
+	"SOA":   dnsmessage.TypeSOA,
+	"CAA":   typeCAA,
+	"SRV":   dnsmessage.TypeSRV,
+}
+
+// ValidDNSRecordType reports whether typ is a record type dns targets can check.
+func ValidDNSRecordType(typ string) bool {
+	_, ok := dnsRecordTypes[strings.ToUpper(typ)]
+	return ok
+}
+
+// checkDNSRecord queries a single record type, optionally against a specific
+// resolver, and compares the answer set with the expected values. The record
+// type is stored in the target's selector and the expected values, comma
+// separated, in its expect field.
+func checkDNSRecord(ctx context.Context, target *db.Target) *Result {
+	start := time.Now()
+	result := &Result{}
+
+	recordType := strings.ToUpper(target.Selector)
+	qtype, ok := dnsRecordTypes[recordType]
+	if !ok {
+		result.Status = "error"
+		result.Error = fmt.Sprintf("unsupported DNS record type %q", target.Selector)
+		return result
+	}
+
+	timeout := time.Duration(target.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	host := target.URL
+	if strings.Contains(host, "://") {
+		if u, err := url.Parse(host); err == nil {
+			host = u.Hostname()
+		}
+	}
+
+	server := target.Resolver
+	if server == "" {
+		server = systemNameserver()
+	}
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	answers, err := queryDNS(ctx, server, host, qtype)
+	result.ResponseTime = time.Since(start)
+	if err != nil {
+		result.Status = "down"
+		result.Error = err.Error()
+		return result
+	}
+
+	// The answer set is order-independent; sort it so the snapshot only
+	// changes when the records do.
+	slices.Sort(answers)
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s %s @%s\n", host, recordType, server))
+	for _, a := range answers {
+		sb.WriteString(a + "\n")
+	}
+	result.Content = sb.String()
+	hash := sha256.Sum256([]byte(result.Content))
+	result.ContentHash = fmt.Sprintf("%x", hash)
+
+	if len(answers) == 0 {
+		result.Status = "down"
+		result.Error = fmt.Sprintf("no %s records for %s", recordType, host)
+		return result
+	}
+
+	if target.Expect != "" {
+		var want []string
+		for _, v := range strings.Split(target.Expect, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				want = append(want, normalizeDNSValue(qtype, v))
+			}
+		}
+		slices.Sort(want)
+		if !slices.Equal(answers, want) {
+			result.Status = "down"
+			result.Error = fmt.Sprintf("%s mismatch: got %s, want %s", recordType, strings.Join(answers, ", "), strings.Join(want, ", "))
+			return result
+		}
+	}
+
+	snaps, err := db.GetLatestSnapshots(target.ID, 1)
+	if err == nil && len(snaps) > 0 {
+		if snaps[0].Hash != result.ContentHash {
+			result.Status = "changed"
+		} else {
+			result.Status = "unchanged"
+		}
+	} else {
+		result.Status = "up"
+	}
+	return result
+}
+
+// queryDNS sends a recursive query for name and returns the answers of the
+// requested type, formatted as normalized strings. Truncated UDP responses
+// are retried over TCP.
+func queryDNS(ctx context.Context, server, name string, qtype dnsmessage.Type) ([]string, error) {
+	fqdn := name
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+	qname, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name %q: %w", name, err)
+	}
+	var id [2]byte
+	rand.Read(id[:])
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: binary.BigEndian.Uint16(id[:]), RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := exchangeDNS(ctx, "udp", server, packed, query.Header.ID)
+	if err == nil && resp.Truncated {
+		resp, err = exchangeDNS(ctx, "tcp", server, packed, query.Header.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, fmt.Errorf("%s: no such domain", name)
+	default:
+		return nil, fmt.Errorf("%s: server returned %s", name, resp.RCode)
+	}
+
+	var answers []string
+	for _, rr := range resp.Answers {
+		if rr.Header.Type != qtype {
+			continue // e.g. the CNAME chain leading to an A record
+		}
+		if v, ok := formatDNSAnswer(rr.Body); ok {
+			answers = append(answers, v)
+		}
+	}
+	return answers, nil
+}
+
+// exchangeDNS sends a packed query over network ("udp" or "tcp") and parses
+// the matching response.
+func exchangeDNS(ctx context.Context, network, server string, query []byte, id uint16) (*dnsmessage.Message, error) {
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if d, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(d)
+	}
+
+	var buf []byte
+	if network == "tcp" {
+		msg := make([]byte, 2+len(query))
+		binary.BigEndian.PutUint16(msg, uint16(len(query)))
+		copy(msg[2:], query)
+		if _, err := conn.Write(msg); err != nil {
+			return nil, err
+		}
+		var length [2]byte
+		if _, err := io.ReadFull(conn, length[:]); err != nil {
+			return nil, err
+		}
+		buf = make([]byte, binary.BigEndian.Uint16(length[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return nil, err
+		}
+	} else {
+		if _, err := conn.Write(query); err != nil {
+			return nil, err
+		}
+		buf = make([]byte, 4096)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return nil, err
+			}
+			// Ignore stray responses to earlier queries
+			if n >= 2 && binary.BigEndian.Uint16(buf) == id {
+				buf = buf[:n]
+				break
+			}
+		}
+	}
+
+	var resp dnsmessage.Message
+	if err := resp.Unpack(buf); err != nil {
+		return nil, fmt.Errorf("invalid DNS response: %w", err)
+	}
+	if resp.ID != id {
+		return nil, fmt.Errorf("DNS response ID mismatch")
+	}
+	return &resp, nil
+}
+
+// formatDNSAnswer renders a record's data in the same form expected values
+// are normalized to, without the TTL.
+func formatDNSAnswer(body dnsmessage.ResourceBody) (string, bool) {
+	switch r := body.(type) {
+	case *dnsmessage.AResource:
+		return netip.AddrFrom4(r.A).String(), true
+	case *dnsmessage.AAAAResource:
+		return netip.AddrFrom16(r.AAAA).String(), true
+	case *dnsmessage.CNAMEResource:
+		return dnsName(r.CNAME), true
+	case *dnsmessage.NSResource:
+		return dnsName(r.NS), true
+	case *dnsmessage.MXResource:
+		return fmt.Sprintf("%d %s", r.Pref, dnsName(r.MX)), true
+	case *dnsmessage.TXTResource:
+		return strings.Join(r.TXT, ""), true
+	case *dnsmessage.SOAResource:
+		return fmt.Sprintf("%s %s %d %d %d %d %d", dnsName(r.NS), dnsName(r.MBox), r.Serial, r.Refresh, r.Retry, r.Expire, r.MinTTL), true
+	case *dnsmessage.SRVResource:
+		return fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, dnsName(r.Target)), true
+	case *dnsmessage.UnknownResource:
+		if r.Type == typeCAA {
+			return formatCAA(r.Data)
+		}
+	}
+	return "", false
+}
+
+// formatCAA renders CAA record data (RFC 8659) as `flags tag "value"`.
+func formatCAA(data []byte) (string, bool) {
+	if len(data) < 2 || len(data) < 2+int(data[1]) {
+		return "", false
+	}
+	flags, tagLen := data[0], int(data[1])
+	tag := strings.ToLower(string(data[2 : 2+tagLen]))
+	return fmt.Sprintf("%d %s %q", flags, tag, string(data[2+tagLen:])), true
+}
+
+func dnsName(n dnsmessage.Name) string {
+	return strings.ToLower(strings.TrimSuffix(n.String(), "."))
+}
+
+// normalizeDNSValue brings an expected value into the form produced by
+// formatDNSAnswer, so "Mail.Example.com." matches "mail.example.com".
+func normalizeDNSValue(qtype dnsmessage.Type, v string) string {
+	switch qtype {
+	case dnsmessage.TypeA, dnsmessage.TypeAAAA:
+		if addr, err := netip.ParseAddr(v); err == nil {
+			return addr.String()
+		}
+	case dnsmessage.TypeCNAME, dnsmessage.TypeNS, dnsmessage.TypeMX, dnsmessage.TypeSRV, dnsmessage.TypeSOA:
+		fields := strings.Fields(v)
+		for i, f := range fields {
+			if _, err := strconv.Atoi(f); err != nil {
+				fields[i] = strings.ToLower(strings.TrimSuffix(f, "."))
+			}
+		}
+		return strings.Join(fields, " ")
+	case typeCAA:
+		fields := strings.SplitN(v, " ", 3)
+		if len(fields) == 3 {
+			value := strings.Trim(fields[2], `"`)
+			return fmt.Sprintf("%s %s %q", fields[0], strings.ToLower(fields[1]), value)
+		}
+	}
+	return v
+}
+
+// systemNameserver returns the first nameserver from /etc/resolv.conf,
+// falling back to a local resolver.
+func systemNameserver() string {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return "127.0.0.1:53"
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53")
+		}
+	}
+	return "127.0.0.1:53"
+}
@@ -0,0 +1,334 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/naru-bot/upp/internal/db"
+	"golang.org/x/net/html/charset"
+)
+
+// xmlFeed decodes RSS 2.0 (<rss><channel><item>), RSS 1.0 (<rdf:RDF><item>)
+// and Atom (<feed><entry>) documents.
+type xmlFeed struct {
+	XMLName xml.Name
+	Channel struct {
+		Items []xmlFeedItem `xml:"item"`
+	} `xml:"channel"`
+	Items   []xmlFeedItem `xml:"item"`
+	Entries []xmlFeedItem `xml:"entry"`
+}
+
+type xmlFeedItem struct {
+	Title     string    `xml:"title"`
+	Links     []xmlLink `xml:"link"`
+	GUID      string    `xml:"guid"`
+	ID        string    `xml:"id"`
+	PubDate   string    `xml:"pubDate"`
+	Date      string    `xml:"date"` // dc:date in RSS 1.0
+	Published string    `xml:"published"`
+	Updated   string    `xml:"updated"`
+}
+
+// xmlLink is an RSS <link>url</link> or an Atom <link href="url" rel="..."/>.
+type xmlLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Text string `xml:",chardata"`
+}
+
+// jsonFeed decodes JSON Feed 1.x documents.
+type jsonFeed struct {
+	Version string `json:"version"`
+	Items   []struct {
+		ID            interface{} `json:"id"`
+		URL           string      `json:"url"`
+		Title         string      `json:"title"`
+		Summary       string      `json:"summary"`
+		ContentText   string      `json:"content_text"`
+		DatePublished string      `json:"date_published"`
+		DateModified  string      `json:"date_modified"`
+	} `json:"items"`
+}
+
+var feedDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	time.RFC3339Nano,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"Mon, 2 Jan 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05 -0700",
+	time.RFC822Z,
+	time.RFC822,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// ParseFeed decodes an RSS 2.0, RSS 1.0, Atom or JSON Feed document. Links
+// are resolved against base. Items without a GUID are identified by their
+// link, or failing that their title and date.
+func ParseFeed(body []byte, base *url.URL) ([]db.FeedItem, error) {
+	trimmed := bytes.TrimSpace(body)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseJSONFeed(trimmed, base)
+	}
+
+	var doc xmlFeed
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.CharsetReader = charset.NewReaderLabel
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+	if err := dec.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid feed: %w", err)
+	}
+
+	var raw []xmlFeedItem
+	switch strings.ToLower(doc.XMLName.Local) {
+	case "rss":
+		raw = doc.Channel.Items
+	case "rdf":
+		raw = doc.Items
+	case "feed":
+		raw = doc.Entries
+	default:
+		return nil, fmt.Errorf("invalid feed: unexpected <%s> document, want RSS, Atom or JSON Feed", doc.XMLName.Local)
+	}
+
+	items := make([]db.FeedItem, 0, len(raw))
+	for _, it := range raw {
+		item := db.FeedItem{
+			GUID:      strings.TrimSpace(firstNonEmpty(it.GUID, it.ID)),
+			Title:     collapseSpace(it.Title),
+			Link:      resolveLink(base, feedLink(it.Links)),
+			Published: feedDate(firstNonEmpty(it.PubDate, it.Published, it.Date, it.Updated)),
+		}
+		items = append(items, withGUID(item))
+	}
+	return items, nil
+}
+
+func parseJSONFeed(body []byte, base *url.URL) ([]db.FeedItem, error) {
+	var doc jsonFeed
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("invalid feed: %w", err)
+	}
+	if !strings.Contains(doc.Version, "jsonfeed.org") {
+		return nil, fmt.Errorf("invalid feed: JSON document is not a JSON Feed")
+	}
+	items := make([]db.FeedItem, 0, len(doc.Items))
+	for _, it := range doc.Items {
+		title := collapseSpace(firstNonEmpty(it.Title, it.Summary, it.ContentText))
+		if r := []rune(title); len(r) > 100 {
+			title = string(r[:100]) + "…"
+		}
+		item := db.FeedItem{
+			Title:     title,
+			Link:      resolveLink(base, it.URL),
+			Published: feedDate(firstNonEmpty(it.DatePublished, it.DateModified)),
+		}
+		if it.ID != nil {
+			item.GUID = strings.TrimSpace(fmt.Sprint(it.ID))
+		}
+		items = append(items, withGUID(item))
+	}
+	return items, nil
+}
+
+// feedLink picks an item's link: RSS link text, or the Atom link without a
+// rel or with rel="alternate".
+func feedLink(links []xmlLink) string {
+	for _, l := range links {
+		if text := strings.TrimSpace(l.Text); text != "" {
+			return text
+		}
+	}
+	for _, l := range links {
+		if l.Href != "" && (l.Rel == "" || l.Rel == "alternate") {
+			return l.Href
+		}
+	}
+	return ""
+}
+
+func resolveLink(base *url.URL, link string) string {
+	if link == "" || base == nil {
+		return link
+	}
+	ref, err := url.Parse(link)
+	if err != nil {
+		return link
+	}
+	return base.ResolveReference(ref).String()
+}
+
+// feedDate returns a feed date in RFC 3339, or as given when its format is
+// not recognized.
+func feedDate(s string) string {
+	s = strings.TrimSpace(s)
+	for _, layout := range feedDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.UTC().Format(time.RFC3339)
+		}
+	}
+	return s
+}
+
+func withGUID(item db.FeedItem) db.FeedItem {
+	if item.GUID == "" {
+		item.GUID = item.Link
+	}
+	if item.GUID == "" {
+		item.GUID = item.Title + "|" + item.Published
+	}
+	return item
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if strings.TrimSpace(v) != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// FormatFeedItem renders a feed item on one line: "title — link (date)".
+func FormatFeedItem(item db.FeedItem) string {
+	s := item.Title
+	if s == "" {
+		s = "(untitled)"
+	}
+	if item.Link != "" {
+		s += " — " + item.Link
+	}
+	if item.Published != "" {
+		if t, err := time.Parse(time.RFC3339, item.Published); err == nil {
+			s += " (" + t.Format("2006-01-02 15:04") + ")"
+		} else {
+			s += " (" + item.Published + ")"
+		}
+	}
+	return s
+}
+
+// checkFeed fetches an RSS, Atom or JSON Feed document and reports the
+// items not seen by earlier checks as the change. The first check records
+// the feed's items without reporting them.
+func checkFeed(ctx context.Context, target *db.Target) *Result {
+	start := time.Now()
+	result := &Result{}
+
+	timeout := time.Duration(target.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	client, err := newHTTPClient(target, timeout)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+
+	trace := newTimingTrace(start)
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace.clientTrace()), "GET", target.URL, nil)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+	req.Header.Set("User-Agent", "upp/1.0")
+	req.Header.Set("Accept", "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, */*;q=0.8")
+	if target.Headers != "" {
+		var customHeaders map[string]string
+		if err := json.Unmarshal([]byte(target.Headers), &customHeaders); err == nil {
+			for k, v := range customHeaders {
+				req.Header.Set(k, v)
+			}
+		}
+	}
+
+	resp, err := client.Do(req)
+	result.ResponseTime = time.Since(start)
+	if err != nil {
+		result.Status = "down"
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	result.StatusCode = resp.StatusCode
+
+	bodyStart := time.Now()
+	body, err := io.ReadAll(resp.Body)
+	result.ResponseTime = time.Since(start)
+	result.Timing = trace.result(time.Since(bodyStart))
+	if err != nil {
+		result.Status = "error"
+		result.Error = "failed to read body: " + err.Error()
+		return result
+	}
+	if !isAcceptedStatus(resp.StatusCode, target.AcceptStatus) {
+		result.Status = "down"
+		result.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		return result
+	}
+
+	items, err := ParseFeed(body, resp.Request.URL)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		if IsHTML(resp.Header.Get("Content-Type"), body) {
+			result.Error = "invalid feed: got an HTML page"
+		}
+		return result
+	}
+	result.FeedItems = items
+
+	var sb strings.Builder
+	for _, it := range items {
+		sb.WriteString(FormatFeedItem(it) + "\n")
+	}
+	result.Content = sb.String()
+	hash := sha256.Sum256([]byte(result.Content))
+	result.ContentHash = fmt.Sprintf("%x", hash)
+
+	seen, err := db.GetFeedItemGUIDs(target.ID)
+	if err != nil {
+		result.Status = "error"
+		result.Error = "failed to load seen feed items: " + err.Error()
+		return result
+	}
+	// The items of a feed's first readable fetch are its baseline ("up"),
+	// even when it has none: a blog that starts out empty still reports
+	// its first posts. Checks from before the target was a feed don't
+	// count.
+	if target.FeedHash == "" && len(seen) == 0 {
+		result.Status = "up"
+		return result
+	}
+	for _, it := range items {
+		if !seen[it.GUID] {
+			result.NewItems = append(result.NewItems, it)
+			seen[it.GUID] = true
+		}
+	}
+	if len(result.NewItems) > 0 {
+		result.Status = "changed"
+	} else {
+		result.Status = "unchanged"
+	}
+	return result
+}
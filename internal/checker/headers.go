@@ -0,0 +1,120 @@
+package checker
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// WatchAllHeaders watches every response header except volatile ones.
+const WatchAllHeaders = "all"
+
+// volatileHeaders change on every response, or with the body, and are not
+// watched by "all". Naming one explicitly still watches it.
+var volatileHeaders = map[string]bool{
+	"Age": true, "Date": true, "Expires": true, "Last-Modified": true, "Etag": true,
+	"Set-Cookie": true, "Content-Length": true, "Connection": true, "Keep-Alive": true,
+	"Transfer-Encoding": true, "Server-Timing": true, "Report-To": true, "Nel": true,
+	"X-Request-Id": true, "X-Correlation-Id": true, "X-Trace-Id": true, "Traceparent": true,
+	"Cf-Ray": true, "X-Amz-Cf-Id": true, "X-Amz-Cf-Pop": true, "X-Amz-Request-Id": true,
+	"X-Cache": true, "X-Cache-Hits": true, "X-Served-By": true, "X-Timer": true, "X-Runtime": true,
+}
+
+// HeaderChange is a watched response header that was added, removed or
+// changed between two snapshots. Old is empty for an added header and New
+// for a removed one.
+type HeaderChange struct {
+	Name string `json:"name"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// String renders the change as a diff line, e.g. "- Server: nginx/1.18".
+func (c HeaderChange) String() string {
+	switch {
+	case c.Old == "":
+		return fmt.Sprintf("+ %s: %s", c.Name, c.New)
+	case c.New == "":
+		return fmt.Sprintf("- %s: %s", c.Name, c.Old)
+	default:
+		return fmt.Sprintf("~ %s: %s → %s", c.Name, c.Old, c.New)
+	}
+}
+
+// ValidateWatchHeaders checks a --watch-headers value: "all" or a
+// comma-separated list of header names.
+func ValidateWatchHeaders(spec string) error {
+	if spec == "" || spec == WatchAllHeaders {
+		return nil
+	}
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || strings.ContainsAny(name, " \t:()<>@;\\\"/[]?={}") {
+			return fmt.Errorf("invalid header name %q in --watch-headers", name)
+		}
+	}
+	return nil
+}
+
+// captureHeaders returns the watched response headers as sorted "Name:
+// value" lines, and their hash.
+func captureHeaders(h http.Header, spec string) (string, string) {
+	var names []string
+	if spec == WatchAllHeaders {
+		for name := range h {
+			if !volatileHeaders[name] {
+				names = append(names, name)
+			}
+		}
+	} else {
+		for _, name := range strings.Split(spec, ",") {
+			if name = textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name)); name != "" {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	for _, name := range names {
+		if values := h.Values(name); len(values) > 0 {
+			sb.WriteString(name + ": " + strings.Join(values, ", ") + "\n")
+		}
+	}
+	content := sb.String()
+	hash := sha256.Sum256([]byte(content))
+	return content, fmt.Sprintf("%x", hash)
+}
+
+// parseHeaderSnapshot decodes headers stored by captureHeaders.
+func parseHeaderSnapshot(content string) map[string]string {
+	headers := make(map[string]string)
+	for _, line := range strings.Split(content, "\n") {
+		if name, value, ok := strings.Cut(line, ": "); ok {
+			headers[name] = value
+		}
+	}
+	return headers
+}
+
+// DiffHeaders compares two header snapshots, listing changes by header
+// name.
+func DiffHeaders(oldContent, newContent string) []HeaderChange {
+	old, cur := parseHeaderSnapshot(oldContent), parseHeaderSnapshot(newContent)
+	var changes []HeaderChange
+	for name, v := range cur {
+		if old[name] != v {
+			changes = append(changes, HeaderChange{Name: name, Old: old[name], New: v})
+		}
+	}
+	for name, v := range old {
+		if _, ok := cur[name]; !ok {
+			changes = append(changes, HeaderChange{Name: name, Old: v})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
@@ -0,0 +1,131 @@
+package checker
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/naru-bot/upp/internal/config"
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// heartbeatScheme prefixes the token stored as a heartbeat target's URL.
+const heartbeatScheme = "heartbeat://"
+
+// defaultGrace is how late a ping may be when a heartbeat target sets no
+// grace period.
+const defaultGrace = 60 * time.Second
+
+// Heartbeat ping kinds, from the last segment of the ping URL.
+const (
+	PingSuccess = "success" // /ping/<token>
+	PingStart   = "start"   // /ping/<token>/start
+	PingFail    = "fail"    // /ping/<token>/fail
+)
+
+// NewHeartbeatURL returns the URL of a new heartbeat target: a random token
+// under the heartbeat:// scheme.
+func NewHeartbeatURL() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return heartbeatScheme + hex.EncodeToString(b), nil
+}
+
+// HeartbeatToken returns the token pings for a heartbeat target are sent to.
+func HeartbeatToken(target *db.Target) string {
+	return strings.TrimPrefix(target.URL, heartbeatScheme)
+}
+
+// HeartbeatGrace returns how late a target's ping may be before the target
+// is down.
+func HeartbeatGrace(target *db.Target) time.Duration {
+	if target.Grace <= 0 {
+		return defaultGrace
+	}
+	return time.Duration(target.Grace) * time.Second
+}
+
+// PingResult turns a ping into a check result. kind is PingSuccess,
+// PingStart, PingFail or an exit code; a non-zero exit code is a failure.
+// The duration is measured from the last start ping, if any.
+func PingResult(target *db.Target, kind, body string, at time.Time) (*Result, error) {
+	result := &Result{Status: "up", Content: body, PingBody: body}
+	switch kind {
+	case PingSuccess:
+	case PingFail:
+		result.Status = "down"
+		result.Error = "job reported failure"
+	default:
+		code, err := strconv.Atoi(kind)
+		if err != nil || code < 0 || code > 255 {
+			return nil, fmt.Errorf("unknown ping %q (want start, fail or an exit code)", kind)
+		}
+		if code != 0 {
+			result.Status = "down"
+			result.Error = fmt.Sprintf("job exited with code %d", code)
+		}
+	}
+
+	hb, err := db.GetHeartbeat(target.ID)
+	if err != nil {
+		return nil, err
+	}
+	if hb.StartedAt != nil {
+		result.ResponseTime = at.Sub(*hb.StartedAt)
+	}
+	if err := db.RecordHeartbeat(target.ID, result.Status, result.Error, at); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// HeartbeatDue returns when a heartbeat target is down unless it is pinged
+// first: an interval plus grace period after the last ping or, before the
+// first ping, after the target was added.
+func HeartbeatDue(target *db.Target) (time.Time, error) {
+	hb, err := db.GetHeartbeat(target.ID)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return heartbeatDue(target, hb), nil
+}
+
+func heartbeatDue(target *db.Target, hb *db.Heartbeat) time.Time {
+	interval := time.Duration(target.Interval) * time.Second
+	if interval <= 0 {
+		interval = time.Duration(config.Get().Defaults.Interval) * time.Second
+	}
+	last := target.CreatedAt
+	if hb.LastPingAt != nil {
+		last = *hb.LastPingAt
+	}
+	return last.Add(interval + HeartbeatGrace(target))
+}
+
+// checkHeartbeat reports a heartbeat target down when no ping has arrived
+// by its due time, and otherwise the outcome of the last ping. Pings
+// themselves are received by the daemon.
+func checkHeartbeat(ctx context.Context, target *db.Target) *Result {
+	hb, err := db.GetHeartbeat(target.ID)
+	if err != nil {
+		return &Result{Status: "error", Error: err.Error()}
+	}
+	if time.Now().After(heartbeatDue(target, hb)) {
+		if hb.LastPingAt == nil {
+			since := time.Since(target.CreatedAt).Round(time.Second)
+			return &Result{Status: "down", Error: fmt.Sprintf("no ping received since the target was added %s ago", since)}
+		}
+		since := time.Since(*hb.LastPingAt).Round(time.Second)
+		return &Result{Status: "down", Error: fmt.Sprintf("no ping for %s", since)}
+	}
+	if hb.LastStatus == "down" {
+		return &Result{Status: "down", Error: hb.LastError}
+	}
+	return &Result{Status: "up"}
+}
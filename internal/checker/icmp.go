@@ -0,0 +1,257 @@
+package checker
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/naru-bot/upp/internal/db"
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+	"golang.org/x/net/ipv6"
+)
+
+const (
+	defaultPackets   = 5
+	pingSpacing      = 200 * time.Millisecond // delay between echo requests
+	pingReplyTimeout = 2 * time.Second        // how long to wait for each reply
+)
+
+// PingStats summarizes a burst of ICMP echo requests.
+type PingStats struct {
+	Sent     int
+	Received int
+	Loss     float64 // percentage of requests without a reply
+	Min      time.Duration
+	Avg      time.Duration
+	Max      time.Duration
+	Jitter   time.Duration // mean difference between consecutive round trips
+}
+
+func checkPing(ctx context.Context, target *db.Target) *Result {
+	start := time.Now()
+	result := &Result{}
+
+	timeout := time.Duration(target.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	host := target.URL
+	if strings.Contains(host, "://") {
+		if u, err := url.Parse(host); err == nil {
+			host = u.Hostname()
+		}
+	}
+
+	ip, err := resolvePingAddr(ctx, host)
+	if err != nil {
+		result.Status = "down"
+		result.Error = err.Error()
+		result.ResponseTime = time.Since(start)
+		return result
+	}
+
+	conn, privileged, err := listenICMP(ip)
+	if err != nil {
+		result.Status = "error"
+		result.Error = "cannot open ICMP socket: " + err.Error()
+		result.ResponseTime = time.Since(start)
+		return result
+	}
+	defer conn.Close()
+	// Unblock a pending read as soon as the check is canceled
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	packets := target.Packets
+	if packets <= 0 {
+		packets = defaultPackets
+	}
+
+	stats, err := pingBurst(ctx, conn, ip, privileged, packets)
+	result.ResponseTime = time.Since(start)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+	result.Ping = stats
+	if stats.Received > 0 {
+		result.ResponseTime = stats.Avg
+	}
+
+	maxLoss := target.MaxLoss
+	if maxLoss <= 0 {
+		maxLoss = 100
+	}
+	switch {
+	case stats.Loss >= maxLoss:
+		result.Status = "down"
+		result.Error = fmt.Sprintf("packet loss %.0f%% (%d/%d replies)", stats.Loss, stats.Received, stats.Sent)
+	case target.DegradedLoss > 0 && stats.Loss >= target.DegradedLoss:
+		result.Status = "degraded"
+		result.Error = fmt.Sprintf("packet loss %.0f%% (%d/%d replies)", stats.Loss, stats.Received, stats.Sent)
+	default:
+		result.Status = "up"
+	}
+	return result
+}
+
+// resolvePingAddr resolves host, preferring an IPv4 address.
+func resolvePingAddr(ctx context.Context, host string) (net.IP, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip, nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range addrs {
+		if a.IP.To4() != nil {
+			return a.IP, nil
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses for %s", host)
+	}
+	return addrs[0].IP, nil
+}
+
+// listenICMP opens an unprivileged datagram ICMP socket, falling back to a
+// raw socket where those aren't permitted. It reports whether the raw
+// socket is in use.
+func listenICMP(ip net.IP) (*icmp.PacketConn, bool, error) {
+	network, raw, addr := "udp4", "ip4:icmp", "0.0.0.0"
+	if ip.To4() == nil {
+		network, raw, addr = "udp6", "ip6:ipv6-icmp", "::"
+	}
+	conn, err := icmp.ListenPacket(network, addr)
+	if err == nil {
+		return conn, false, nil
+	}
+	conn, rawErr := icmp.ListenPacket(raw, addr)
+	if rawErr != nil {
+		return nil, false, fmt.Errorf("%v (raw socket: %v)", err, rawErr)
+	}
+	return conn, true, nil
+}
+
+// pingBurst sends count echo requests one after another and collects the
+// round-trip times of the replies.
+func pingBurst(ctx context.Context, conn *icmp.PacketConn, ip net.IP, privileged bool, count int) (*PingStats, error) {
+	var dst net.Addr = &net.UDPAddr{IP: ip}
+	if privileged {
+		dst = &net.IPAddr{IP: ip}
+	}
+	var echoType icmp.Type = ipv4.ICMPTypeEcho
+	proto := 1 // ICMP for IPv4
+	if ip.To4() == nil {
+		echoType = ipv6.ICMPTypeEchoRequest
+		proto = 58 // ICMPv6
+	}
+
+	// A random payload tells our replies apart from those to other checks
+	// sharing a raw socket; datagram sockets also rewrite the ID.
+	token := make([]byte, 16)
+	rand.Read(token)
+	id := int(token[0])<<8 | int(token[1])
+
+	stats := &PingStats{}
+	var rtts []time.Duration
+	buf := make([]byte, 1500)
+
+	for seq := 1; seq <= count; seq++ {
+		if ctx.Err() != nil {
+			break
+		}
+		msg := icmp.Message{
+			Type: echoType,
+			Body: &icmp.Echo{ID: id, Seq: seq, Data: token},
+		}
+		wb, err := msg.Marshal(nil)
+		if err != nil {
+			return nil, err
+		}
+		sent := time.Now()
+		if _, err := conn.WriteTo(wb, dst); err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return nil, fmt.Errorf("send echo request: %w", err)
+		}
+		stats.Sent++
+
+		deadline := sent.Add(pingReplyTimeout)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		conn.SetReadDeadline(deadline)
+		for ctx.Err() == nil {
+			n, _, err := conn.ReadFrom(buf)
+			if err != nil {
+				break // timed out: count as lost
+			}
+			reply, err := icmp.ParseMessage(proto, buf[:n])
+			if err != nil {
+				continue
+			}
+			echo, ok := reply.Body.(*icmp.Echo)
+			if !ok || echo.Seq != seq || !bytes.Equal(echo.Data, token) {
+				continue
+			}
+			if reply.Type != ipv4.ICMPTypeEchoReply && reply.Type != ipv6.ICMPTypeEchoReply {
+				continue
+			}
+			rtts = append(rtts, time.Since(sent))
+			break
+		}
+
+		if seq < count {
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Until(sent.Add(pingSpacing))):
+			}
+		}
+	}
+
+	if stats.Sent == 0 {
+		return nil, fmt.Errorf("ping canceled: %w", ctx.Err())
+	}
+	stats.Received = len(rtts)
+	stats.Loss = float64(stats.Sent-stats.Received) / float64(stats.Sent) * 100
+	if len(rtts) == 0 {
+		return stats, nil
+	}
+
+	var total, diffs time.Duration
+	stats.Min, stats.Max = rtts[0], rtts[0]
+	for i, rtt := range rtts {
+		total += rtt
+		if rtt < stats.Min {
+			stats.Min = rtt
+		}
+		if rtt > stats.Max {
+			stats.Max = rtt
+		}
+		if i > 0 {
+			d := rtt - rtts[i-1]
+			if d < 0 {
+				d = -d
+			}
+			diffs += d
+		}
+	}
+	stats.Avg = total / time.Duration(len(rtts))
+	if len(rtts) > 1 {
+		stats.Jitter = diffs / time.Duration(len(rtts)-1)
+	}
+	return stats, nil
+}
@@ -0,0 +1,117 @@
+package checker
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/itchyny/gojq"
+	"github.com/naru-bot/upp/internal/config"
+	"github.com/naru-bot/upp/internal/db"
+)
+
+var (
+	numberPattern     = regexp.MustCompile(`\d+(?:[.,]\d+)*`)
+	whitespacePattern = regexp.MustCompile(`\s+`)
+)
+
+// ParseNormalize decodes the normalization rules stored with a target.
+func ParseNormalize(data string) (config.Normalize, error) {
+	var n config.Normalize
+	if data == "" {
+		return n, nil
+	}
+	if err := json.Unmarshal([]byte(data), &n); err != nil {
+		return n, fmt.Errorf("invalid normalization rules: %w", err)
+	}
+	return n, nil
+}
+
+// ValidateNormalize checks that regexes and jq paths compile, so mistakes
+// surface when a target is added rather than on every check.
+func ValidateNormalize(n config.Normalize) error {
+	for _, re := range n.IgnoreRegex {
+		if _, err := regexp.Compile(re); err != nil {
+			return fmt.Errorf("invalid ignore regex %q: %w", re, err)
+		}
+	}
+	for _, path := range n.JQDelete {
+		if _, err := gojq.Parse("del(" + path + ")"); err != nil {
+			return fmt.Errorf("invalid jq delete path %q: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// targetNormalize returns the global rules from config.yml combined with
+// the target's own.
+func targetNormalize(target *db.Target) (config.Normalize, error) {
+	own, err := ParseNormalize(target.Normalize)
+	if err != nil {
+		return own, err
+	}
+	rules := config.Get().Normalize.Merge(own)
+	if err := ValidateNormalize(rules); err != nil {
+		return rules, err
+	}
+	return rules, nil
+}
+
+// NormalizedContent returns exactly what is hashed for change detection of
+// a target's extracted content: known dynamic tokens and the ignore regexes
+// removed, then numbers, case and whitespace normalized as configured.
+func NormalizedContent(target *db.Target, content string) (string, error) {
+	rules, err := targetNormalize(target)
+	if err != nil {
+		return "", err
+	}
+	return normalizeContent(content, rules), nil
+}
+
+func normalizeContent(content string, rules config.Normalize) string {
+	content = stripDynamicContent(content)
+	for _, expr := range rules.IgnoreRegex {
+		if re, err := regexp.Compile(expr); err == nil {
+			content = re.ReplaceAllString(content, "")
+		}
+	}
+	if rules.IgnoreNumbers {
+		content = numberPattern.ReplaceAllString(content, "#")
+	}
+	if rules.IgnoreCase {
+		content = strings.ToLower(content)
+	}
+	if rules.Whitespace {
+		content = strings.TrimSpace(whitespacePattern.ReplaceAllString(content, " "))
+	}
+	return content
+}
+
+// deleteJSONPaths removes the given paths, e.g. ".meta.generated_at" or
+// ".items[].views", from decoded JSON.
+func deleteJSONPaths(data interface{}, paths []string) (interface{}, error) {
+	for _, path := range paths {
+		query, err := gojq.Parse("del(" + path + ")")
+		if err != nil {
+			return nil, fmt.Errorf("invalid jq delete path %q: %w", path, err)
+		}
+		v, ok := query.Run(data).Next()
+		if !ok {
+			continue
+		}
+		if err, isErr := v.(error); isErr {
+			return nil, fmt.Errorf("jq delete %s: %w", path, err)
+		}
+		data = v
+	}
+	return data, nil
+}
+
+// dropSelectors removes every element matching the selectors from doc.
+func dropSelectors(doc *goquery.Document, selectors []string) {
+	for _, sel := range selectors {
+		doc.Find(sel).Remove()
+	}
+}
@@ -0,0 +1,119 @@
+package checker
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/naru-bot/upp/internal/config"
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// proxyDirect as a target's proxy bypasses the configured default and the
+// environment.
+const proxyDirect = "direct"
+
+// ValidateProxy checks a proxy URL as accepted by --proxy and the proxy
+// config key: http, https, socks5 or socks5h, optionally with user:pass.
+func ValidateProxy(raw string) error {
+	if raw == "" || raw == proxyDirect {
+		return nil
+	}
+	_, err := parseProxy(raw)
+	return err
+}
+
+func parseProxy(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy URL: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid proxy URL %q: scheme must be http, https, socks5 or socks5h", u.Redacted())
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q: missing host", u.Redacted())
+	}
+	return u, nil
+}
+
+// proxySetting returns the proxy that applies to a target and where it was
+// set: the target itself, the proxy config key, or neither (""), in which
+// case the standard HTTP_PROXY/HTTPS_PROXY/NO_PROXY variables apply.
+func proxySetting(target *db.Target) (raw, source string) {
+	if target.Proxy != "" {
+		return target.Proxy, "target"
+	}
+	if p := config.Get().Proxy; p != "" {
+		return p, "config"
+	}
+	return "", ""
+}
+
+// ProxyFunc returns the http.Transport proxy function for a target. An
+// invalid proxy URL fails every request with the parse error.
+func ProxyFunc(target *db.Target) func(*http.Request) (*url.URL, error) {
+	raw, _ := proxySetting(target)
+	switch raw {
+	case "":
+		return http.ProxyFromEnvironment
+	case proxyDirect:
+		return nil
+	}
+	u, err := parseProxy(raw)
+	if err != nil {
+		return func(*http.Request) (*url.URL, error) { return nil, err }
+	}
+	return http.ProxyURL(u)
+}
+
+// DescribeProxy reports the proxy checks of a target go through, with any
+// password redacted, e.g. "socks5://bastion:1080 (config)". It returns ""
+// when no proxy is set anywhere.
+func DescribeProxy(target *db.Target) string {
+	raw, source := proxySetting(target)
+	switch raw {
+	case proxyDirect:
+		return fmt.Sprintf("direct (%s)", source)
+	case "":
+		req, err := http.NewRequest("GET", target.URL, nil)
+		if err != nil {
+			return ""
+		}
+		u, err := http.ProxyFromEnvironment(req)
+		if err != nil || u == nil {
+			return ""
+		}
+		return u.Redacted() + " (environment)"
+	}
+	u, err := parseProxy(raw)
+	if err != nil {
+		return fmt.Sprintf("%s (%s, invalid: %v)", raw, source, err)
+	}
+	return fmt.Sprintf("%s (%s)", u.Redacted(), source)
+}
+
+// browserProxyArg returns the --proxy-server flag for a headless browser,
+// or "" to let the browser use the environment. Browsers don't accept
+// proxy credentials on the command line.
+func browserProxyArg(target *db.Target) (string, error) {
+	raw, _ := proxySetting(target)
+	switch raw {
+	case "":
+		return "", nil
+	case proxyDirect:
+		return "--no-proxy-server", nil
+	}
+	u, err := parseProxy(raw)
+	if err != nil {
+		return "", err
+	}
+	if u.User != nil {
+		return "", fmt.Errorf("visual checks can't use a proxy with credentials (%s)", u.Redacted())
+	}
+	scheme := strings.TrimSuffix(u.Scheme, "h") // socks5h: the browser resolves through SOCKS5 anyway
+	return fmt.Sprintf("--proxy-server=%s://%s", scheme, u.Host), nil
+}
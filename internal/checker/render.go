@@ -0,0 +1,324 @@
+package checker
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// Snapshot rendering modes for HTML responses.
+const (
+	RenderText     = "text"     // readable text (default)
+	RenderMarkdown = "markdown" // Markdown: headings, lists, links and tables
+	RenderRaw      = "raw"      // the response body as is
+)
+
+// ValidateRender checks a rendering mode as accepted by --render.
+func ValidateRender(mode string) error {
+	switch mode {
+	case "", RenderText, RenderMarkdown, RenderRaw:
+		return nil
+	}
+	return fmt.Errorf("invalid render mode %q (must be text, markdown or raw)", mode)
+}
+
+// IsHTML reports whether a response is an HTML page, going by its
+// Content-Type or, without one, by sniffing the body.
+func IsHTML(contentType string, body []byte) bool {
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	return strings.Contains(strings.ToLower(contentType), "html")
+}
+
+// RenderHTML converts an HTML page to readable text, or to Markdown when
+// mode is "markdown". Headings, paragraphs, list items, links with their
+// targets and table rows are kept; scripts, styles and other invisible
+// elements are dropped.
+func RenderHTML(page, mode string) string {
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		return page
+	}
+	r := &htmlRenderer{markdown: mode == RenderMarkdown, buf: &strings.Builder{}}
+	r.walk(doc)
+	return cleanRendered(r.buf.String())
+}
+
+var (
+	blankLinesPattern = regexp.MustCompile(`\n{3,}`)
+	inlineSpace       = regexp.MustCompile(`[ \t\r\n\f]+`)
+)
+
+// skippedElements are never visible to a reader.
+var skippedElements = map[string]bool{
+	"head": true, "script": true, "style": true, "noscript": true, "template": true,
+	"svg": true, "canvas": true, "iframe": true, "object": true, "select": true,
+}
+
+var blockElements = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "main": true,
+	"header": true, "footer": true, "nav": true, "aside": true, "blockquote": true,
+	"form": true, "fieldset": true, "figure": true, "figcaption": true, "address": true,
+	"dl": true, "dt": true, "dd": true, "details": true, "summary": true, "body": true,
+}
+
+type htmlRenderer struct {
+	markdown bool
+	buf      *strings.Builder
+	lists    []int // item counters of the enclosing lists, -1 for unordered
+}
+
+func (r *htmlRenderer) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		r.text(n.Data)
+		return
+	case html.ElementNode:
+	default:
+		r.children(n)
+		return
+	}
+	if skippedElements[n.Data] || hasAttr(n, "hidden") {
+		return
+	}
+
+	switch n.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		r.block()
+		if r.markdown {
+			level, _ := strconv.Atoi(n.Data[1:])
+			r.buf.WriteString(strings.Repeat("#", level) + " ")
+		}
+		r.text(collapseSpace(r.capture(n)))
+		r.block()
+	case "br":
+		r.buf.WriteString("\n")
+	case "hr":
+		r.block()
+		if r.markdown {
+			r.buf.WriteString("---")
+		}
+		r.block()
+	case "ul", "ol":
+		if len(r.lists) == 0 {
+			r.block()
+		}
+		counter := -1
+		if n.Data == "ol" {
+			counter = 0
+		}
+		r.lists = append(r.lists, counter)
+		r.children(n)
+		r.lists = r.lists[:len(r.lists)-1]
+		if len(r.lists) == 0 {
+			r.block()
+		}
+	case "li":
+		r.newline()
+		marker := "- "
+		if depth := len(r.lists); depth > 0 {
+			r.buf.WriteString(strings.Repeat("  ", depth-1))
+			if r.lists[depth-1] >= 0 {
+				r.lists[depth-1]++
+				marker = fmt.Sprintf("%d. ", r.lists[depth-1])
+			}
+		}
+		r.buf.WriteString(marker)
+		r.children(n)
+		r.newline()
+	case "a":
+		r.link(n)
+	case "img":
+		if alt := strings.TrimSpace(attr(n, "alt")); alt != "" {
+			r.text(alt)
+		}
+	case "pre":
+		r.block()
+		if r.markdown {
+			r.buf.WriteString("```\n" + strings.Trim(textContent(n), "\n") + "\n```")
+		} else {
+			r.buf.WriteString(strings.Trim(textContent(n), "\n"))
+		}
+		r.block()
+	case "code":
+		if r.markdown {
+			r.text("`" + collapseSpace(r.capture(n)) + "`")
+		} else {
+			r.children(n)
+		}
+	case "table":
+		r.block()
+		r.table(n)
+		r.block()
+	default:
+		if blockElements[n.Data] {
+			r.block()
+			r.children(n)
+			r.block()
+		} else {
+			r.children(n)
+		}
+	}
+}
+
+func (r *htmlRenderer) children(n *html.Node) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		r.walk(c)
+	}
+}
+
+// capture renders n's children into a separate buffer and returns them.
+func (r *htmlRenderer) capture(n *html.Node) string {
+	saved := r.buf
+	r.buf = &strings.Builder{}
+	r.children(n)
+	out := r.buf.String()
+	r.buf = saved
+	return out
+}
+
+// text writes inline text with runs of whitespace collapsed.
+func (r *htmlRenderer) text(s string) {
+	s = inlineSpace.ReplaceAllString(s, " ")
+	out := r.buf.String()
+	if len(out) == 0 || strings.HasSuffix(out, "\n") || strings.HasSuffix(out, " ") {
+		s = strings.TrimLeft(s, " ")
+	}
+	r.buf.WriteString(s)
+}
+
+// block ends the current paragraph.
+func (r *htmlRenderer) block() {
+	out := r.buf.String()
+	switch {
+	case len(out) == 0 || strings.HasSuffix(out, "\n\n"):
+	case strings.HasSuffix(out, "\n"):
+		r.buf.WriteString("\n")
+	default:
+		r.buf.WriteString("\n\n")
+	}
+}
+
+// newline ends the current line.
+func (r *htmlRenderer) newline() {
+	if out := r.buf.String(); len(out) > 0 && !strings.HasSuffix(out, "\n") {
+		r.buf.WriteString("\n")
+	}
+}
+
+// link writes a link's text with its target: "text (href)", or
+// "[text](href)" in Markdown. In-page and javascript: links are written as
+// plain text.
+func (r *htmlRenderer) link(n *html.Node) {
+	label := collapseSpace(r.capture(n))
+	href := strings.TrimSpace(attr(n, "href"))
+	if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+		r.text(label)
+		return
+	}
+	switch {
+	case r.markdown:
+		if label == "" {
+			label = href
+		}
+		r.text(fmt.Sprintf("[%s](%s)", label, href))
+	case label == "" || label == href:
+		r.text(href)
+	default:
+		r.text(fmt.Sprintf("%s (%s)", label, href))
+	}
+}
+
+// table writes one line per row with cells separated by " | ". In Markdown
+// a header row of <th> cells is followed by a separator line.
+func (r *htmlRenderer) table(n *html.Node) {
+	first := true
+	var rows func(*html.Node)
+	rows = func(n *html.Node) {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type != html.ElementNode {
+				continue
+			}
+			switch c.Data {
+			case "thead", "tbody", "tfoot":
+				rows(c)
+			case "caption":
+				r.text(collapseSpace(r.capture(c)))
+				r.newline()
+			case "tr":
+				var cells []string
+				header := true
+				for cell := c.FirstChild; cell != nil; cell = cell.NextSibling {
+					if cell.Type != html.ElementNode || (cell.Data != "td" && cell.Data != "th") {
+						continue
+					}
+					header = header && cell.Data == "th"
+					cells = append(cells, collapseSpace(r.capture(cell)))
+				}
+				if len(cells) == 0 {
+					continue
+				}
+				r.newline()
+				if r.markdown {
+					r.buf.WriteString("| " + strings.Join(cells, " | ") + " |")
+					if first && header {
+						r.buf.WriteString("\n|" + strings.Repeat(" --- |", len(cells)))
+					}
+				} else {
+					r.buf.WriteString(strings.Join(cells, " | "))
+				}
+				first = false
+			}
+		}
+	}
+	rows(n)
+}
+
+func collapseSpace(s string) string {
+	return strings.TrimSpace(inlineSpace.ReplaceAllString(s, " "))
+}
+
+func textContent(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var sb strings.Builder
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		sb.WriteString(textContent(c))
+	}
+	return sb.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasAttr(n *html.Node, key string) bool {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanRendered trims trailing spaces from every line and collapses runs of
+// blank lines.
+func cleanRendered(s string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	s = strings.Join(lines, "\n")
+	return strings.TrimSpace(blankLinesPattern.ReplaceAllString(s, "\n\n")) + "\n"
+}
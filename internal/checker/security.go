@@ -0,0 +1,473 @@
+package checker
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// Severities of security findings.
+const (
+	SeverityHigh   = "high"
+	SeverityMedium = "medium"
+	SeverityLow    = "low"
+)
+
+// hstsMinAge is the HSTS max-age below which a policy is too short to
+// protect returning visitors (six months).
+const hstsMinAge = 15768000
+
+// maxCookiePenalty caps the points lost to cookie flags, so a site setting
+// many cookies isn't graded on cookies alone.
+const maxCookiePenalty = 20
+
+// probeTimeout bounds each protocol and cipher probe; servers that drop
+// unsupported handshakes instead of rejecting them would otherwise use up
+// the whole check timeout.
+const probeTimeout = 5 * time.Second
+
+// SecurityFinding is a weakness in a server's headers or TLS configuration
+// and the points it costs.
+type SecurityFinding struct {
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	Penalty  int    `json:"penalty"`
+}
+
+// String renders the finding as "[high -20] message".
+func (f SecurityFinding) String() string {
+	if f.Penalty == 0 {
+		return fmt.Sprintf("[%s] %s", f.Severity, f.Message)
+	}
+	return fmt.Sprintf("[%s -%d] %s", f.Severity, f.Penalty, f.Message)
+}
+
+// SecurityReport is the graded outcome of a security check.
+type SecurityReport struct {
+	Score         int               `json:"score"`
+	Grade         string            `json:"grade"`
+	Protocols     []string          `json:"protocols"`
+	Ciphers       []string          `json:"ciphers"`
+	Findings      []SecurityFinding `json:"findings"`
+	PreviousScore *int              `json:"previous_score,omitempty"`
+	PreviousGrade string            `json:"previous_grade,omitempty"`
+}
+
+// Summary describes the grade, e.g. "grade B (84/100), 5 findings", or
+// how it moved since the last snapshot.
+func (r *SecurityReport) Summary() string {
+	if r.PreviousScore != nil && *r.PreviousScore != r.Score {
+		return fmt.Sprintf("grade %s (%d/100) → %s (%d/100)", r.PreviousGrade, *r.PreviousScore, r.Grade, r.Score)
+	}
+	noun := "findings"
+	if len(r.Findings) == 1 {
+		noun = "finding"
+	}
+	return fmt.Sprintf("grade %s (%d/100), %d %s", r.Grade, r.Score, len(r.Findings), noun)
+}
+
+// securityGrade maps a score to a letter grade.
+func securityGrade(score int) string {
+	switch {
+	case score >= 90:
+		return "A"
+	case score >= 80:
+		return "B"
+	case score >= 70:
+		return "C"
+	case score >= 60:
+		return "D"
+	}
+	return "F"
+}
+
+// ParseSecurityGrade reads the grade and score from a security snapshot.
+func ParseSecurityGrade(content string) (grade string, score int, ok bool) {
+	line, _, _ := strings.Cut(content, "\n")
+	if _, err := fmt.Sscanf(line, "Grade: %s (%d/100)", &grade, &score); err != nil {
+		return "", 0, false
+	}
+	return grade, score, true
+}
+
+// formatSecurityContent renders a report as the snapshot, so that any
+// change in grade, protocols, ciphers or findings changes the hash.
+func formatSecurityContent(r *SecurityReport) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "Grade: %s (%d/100)\n", r.Grade, r.Score)
+	fmt.Fprintf(&sb, "Protocols: %s\n", strings.Join(r.Protocols, ", "))
+	sb.WriteString("Ciphers:\n")
+	for _, c := range r.Ciphers {
+		sb.WriteString("  " + c + "\n")
+	}
+	sb.WriteString("Findings:\n")
+	for _, f := range r.Findings {
+		fmt.Fprintf(&sb, "  %s\n", f)
+	}
+	return sb.String()
+}
+
+// checkSecurity grades an HTTPS endpoint: its security headers and cookie
+// flags, and the TLS protocol versions and cipher suites it accepts. The
+// score and findings are the snapshot, so a grade change is reported as
+// "changed"; the score is also recorded as the target's value.
+func checkSecurity(ctx context.Context, target *db.Target) *Result {
+	start := time.Now()
+	result := &Result{}
+
+	u, err := url.Parse(target.URL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		result.Status = "error"
+		result.Error = "security checks need an https:// URL"
+		return result
+	}
+
+	timeout := time.Duration(target.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	client, err := newHTTPClient(target, timeout)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+
+	trace := newTimingTrace(start)
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace.clientTrace()), "GET", target.URL, nil)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+	req.Header.Set("User-Agent", "upp/1.0")
+	if target.Headers != "" {
+		var customHeaders map[string]string
+		if err := json.Unmarshal([]byte(target.Headers), &customHeaders); err == nil {
+			for k, v := range customHeaders {
+				req.Header.Set(k, v)
+			}
+		}
+	}
+
+	resp, err := client.Do(req)
+	result.ResponseTime = time.Since(start)
+	if err != nil {
+		result.Status = "down"
+		result.Error = err.Error()
+		return result
+	}
+	bodyStart := time.Now()
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	result.ResponseTime = time.Since(start)
+	result.Timing = trace.result(time.Since(bodyStart))
+	result.StatusCode = resp.StatusCode
+	if !isAcceptedStatus(resp.StatusCode, target.AcceptStatus) {
+		result.Status = "down"
+		result.Error = fmt.Sprintf("HTTP %d", resp.StatusCode)
+		return result
+	}
+
+	report := &SecurityReport{}
+	if resp.Request.URL.Scheme != "https" {
+		report.Findings = append(report.Findings, SecurityFinding{SeverityHigh, "Redirects to plain HTTP (" + resp.Request.URL.String() + ")", 30})
+	} else {
+		report.Findings = append(report.Findings, headerFindings(resp.Header)...)
+	}
+	report.Findings = append(report.Findings, cookieFindings(resp.Cookies())...)
+
+	host, addr, _ := tlsAddress(target.URL)
+	protocols, ciphers, err := probeTLS(ctx, target, host, addr)
+	if err != nil {
+		result.Status = "down"
+		result.Error = err.Error()
+		return result
+	}
+	report.Protocols, report.Ciphers = protocols, ciphers
+	report.Findings = append(report.Findings, tlsFindings(protocols, ciphers)...)
+
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		if report.Findings[i].Penalty != report.Findings[j].Penalty {
+			return report.Findings[i].Penalty > report.Findings[j].Penalty
+		}
+		return report.Findings[i].Message < report.Findings[j].Message
+	})
+	report.Score = 100
+	for _, f := range report.Findings {
+		report.Score -= f.Penalty
+	}
+	if report.Score < 0 {
+		report.Score = 0
+	}
+	report.Grade = securityGrade(report.Score)
+	result.Security = report
+	score := float64(report.Score)
+	result.Value = &score
+
+	result.Content = formatSecurityContent(report)
+	hash := sha256.Sum256([]byte(result.Content))
+	result.ContentHash = fmt.Sprintf("%x", hash)
+
+	snaps, err := db.GetLatestSnapshots(target.ID, 1)
+	if err == nil && len(snaps) > 0 {
+		if snaps[0].Hash != result.ContentHash {
+			result.Status = "changed"
+			if grade, score, ok := ParseSecurityGrade(snaps[0].Content); ok {
+				report.PreviousGrade, report.PreviousScore = grade, &score
+			}
+		} else {
+			result.Status = "unchanged"
+		}
+	} else {
+		result.Status = "up"
+	}
+	return result
+}
+
+var hstsMaxAge = regexp.MustCompile(`(?i)max-age\s*=\s*"?(\d+)"?`)
+
+// headerFindings grades the security headers of an HTTPS response.
+func headerFindings(h http.Header) []SecurityFinding {
+	var findings []SecurityFinding
+
+	if hsts := h.Get("Strict-Transport-Security"); hsts == "" {
+		findings = append(findings, SecurityFinding{SeverityHigh, "No Strict-Transport-Security header", 20})
+	} else {
+		age := -1
+		if m := hstsMaxAge.FindStringSubmatch(hsts); m != nil {
+			age, _ = strconv.Atoi(m[1])
+		}
+		switch {
+		case age < 0:
+			findings = append(findings, SecurityFinding{SeverityHigh, "Strict-Transport-Security has no max-age", 20})
+		case age == 0:
+			findings = append(findings, SecurityFinding{SeverityHigh, "Strict-Transport-Security max-age=0 disables HSTS", 20})
+		case age < hstsMinAge:
+			findings = append(findings, SecurityFinding{SeverityMedium, fmt.Sprintf("Strict-Transport-Security max-age is %ds, below 6 months", age), 10})
+		}
+		if age > 0 && !strings.Contains(strings.ToLower(hsts), "preload") {
+			findings = append(findings, SecurityFinding{SeverityLow, "Strict-Transport-Security is not marked for preload", 2})
+		}
+	}
+
+	csp := h.Get("Content-Security-Policy")
+	switch {
+	case csp == "" && h.Get("Content-Security-Policy-Report-Only") != "":
+		findings = append(findings, SecurityFinding{SeverityMedium, "Content-Security-Policy is only set in report-only mode", 10})
+	case csp == "":
+		findings = append(findings, SecurityFinding{SeverityHigh, "No Content-Security-Policy header", 20})
+	default:
+		scripts, ok := cspDirective(csp, "script-src")
+		if !ok {
+			scripts, _ = cspDirective(csp, "default-src")
+		}
+		if strings.Contains(scripts, "'unsafe-inline'") || strings.Contains(scripts, "'unsafe-eval'") {
+			findings = append(findings, SecurityFinding{SeverityMedium, "Content-Security-Policy allows unsafe-inline or unsafe-eval scripts", 5})
+		}
+	}
+
+	_, frameAncestors := cspDirective(csp, "frame-ancestors")
+	switch xfo := strings.ToUpper(strings.TrimSpace(h.Get("X-Frame-Options"))); {
+	case xfo == "" && !frameAncestors:
+		findings = append(findings, SecurityFinding{SeverityMedium, "No X-Frame-Options header or CSP frame-ancestors", 10})
+	case xfo != "" && xfo != "DENY" && xfo != "SAMEORIGIN":
+		findings = append(findings, SecurityFinding{SeverityLow, fmt.Sprintf("X-Frame-Options %q is not DENY or SAMEORIGIN", xfo), 5})
+	}
+
+	if !strings.EqualFold(strings.TrimSpace(h.Get("X-Content-Type-Options")), "nosniff") {
+		findings = append(findings, SecurityFinding{SeverityMedium, "X-Content-Type-Options is not nosniff", 5})
+	}
+
+	switch rp := strings.ToLower(h.Get("Referrer-Policy")); {
+	case rp == "":
+		findings = append(findings, SecurityFinding{SeverityLow, "No Referrer-Policy header", 5})
+	case strings.Contains(rp, "unsafe-url"):
+		findings = append(findings, SecurityFinding{SeverityLow, "Referrer-Policy unsafe-url leaks full URLs to other sites", 5})
+	}
+	return findings
+}
+
+// cspDirective returns the sources of a CSP directive, and whether the
+// policy sets it.
+func cspDirective(csp, name string) (string, bool) {
+	for _, d := range strings.Split(csp, ";") {
+		fields := strings.Fields(d)
+		if len(fields) > 0 && strings.EqualFold(fields[0], name) {
+			return strings.Join(fields[1:], " "), true
+		}
+	}
+	return "", false
+}
+
+// cookieFindings grades the flags of the cookies a response sets.
+func cookieFindings(cookies []*http.Cookie) []SecurityFinding {
+	var findings []SecurityFinding
+	total := 0
+	add := func(severity, msg string, penalty int) {
+		penalty = min(penalty, maxCookiePenalty-total)
+		total += penalty
+		findings = append(findings, SecurityFinding{severity, msg, penalty})
+	}
+	for _, c := range cookies {
+		if !c.Secure {
+			add(SeverityMedium, fmt.Sprintf("Cookie %s is not Secure", c.Name), 5)
+		}
+		if !c.HttpOnly {
+			add(SeverityLow, fmt.Sprintf("Cookie %s is not HttpOnly", c.Name), 2)
+		}
+		if c.SameSite == http.SameSiteDefaultMode {
+			add(SeverityLow, fmt.Sprintf("Cookie %s has no SameSite attribute", c.Name), 2)
+		}
+	}
+	return findings
+}
+
+// probeTLS handshakes once per protocol version and once per TLS 1.2 cipher
+// suite to list what the server accepts. TLS 1.3 suites can't be offered
+// one at a time, so only the one the server picks is listed. Probes connect
+// directly, not through the target's proxy.
+func probeTLS(ctx context.Context, target *db.Target, host, addr string) ([]string, []string, error) {
+	base, err := targetTLSConfig(target)
+	if err != nil {
+		return nil, nil, err
+	}
+	base.ServerName = host
+	// The certificate was verified by the request; probes only negotiate
+	base.InsecureSkipVerify = true
+
+	probe := func(version uint16, suite uint16) (*tls.ConnectionState, error) {
+		cfg := base.Clone()
+		cfg.MinVersion, cfg.MaxVersion = version, version
+		if suite != 0 {
+			cfg.MinVersion = tls.VersionTLS10
+			cfg.CipherSuites = []uint16{suite}
+		}
+		ctx, cancel := context.WithTimeout(ctx, probeTimeout)
+		defer cancel()
+		conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		defer conn.Close()
+		tc := tls.Client(conn, cfg)
+		if err := tc.HandshakeContext(ctx); err != nil {
+			return nil, nil
+		}
+		state := tc.ConnectionState()
+		return &state, nil
+	}
+
+	var protocols []string
+	cipherSet := make(map[string]bool)
+	maxLegacy := uint16(0)
+	for _, v := range []uint16{tls.VersionTLS10, tls.VersionTLS11, tls.VersionTLS12, tls.VersionTLS13} {
+		state, err := probe(v, 0)
+		if err != nil {
+			return nil, nil, fmt.Errorf("TLS probe: %w", err)
+		}
+		if state == nil {
+			continue
+		}
+		protocols = append(protocols, tls.VersionName(v))
+		if v == tls.VersionTLS13 {
+			cipherSet[tls.CipherSuiteName(state.CipherSuite)] = true
+		} else {
+			maxLegacy = v
+		}
+	}
+	if len(protocols) == 0 {
+		return nil, nil, errors.New("TLS probe: server accepted no protocol version")
+	}
+
+	if maxLegacy != 0 {
+		suites := append(tls.CipherSuites(), tls.InsecureCipherSuites()...)
+		for _, s := range suites {
+			if !supportsVersion(s, maxLegacy) {
+				continue
+			}
+			state, err := probe(maxLegacy, s.ID)
+			if err != nil {
+				return nil, nil, fmt.Errorf("TLS probe: %w", err)
+			}
+			if state != nil {
+				cipherSet[s.Name] = true
+			}
+		}
+	}
+
+	ciphers := make([]string, 0, len(cipherSet))
+	for c := range cipherSet {
+		ciphers = append(ciphers, c)
+	}
+	sort.Strings(ciphers)
+	return protocols, ciphers, nil
+}
+
+func supportsVersion(s *tls.CipherSuite, version uint16) bool {
+	for _, v := range s.SupportedVersions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// tlsFindings grades the accepted protocol versions and cipher suites.
+func tlsFindings(protocols, ciphers []string) []SecurityFinding {
+	var findings []SecurityFinding
+	accepted := make(map[string]bool)
+	for _, p := range protocols {
+		accepted[p] = true
+	}
+	if accepted["TLS 1.0"] {
+		findings = append(findings, SecurityFinding{SeverityHigh, "Accepts TLS 1.0", 15})
+	}
+	if accepted["TLS 1.1"] {
+		findings = append(findings, SecurityFinding{SeverityMedium, "Accepts TLS 1.1", 10})
+	}
+	if !accepted["TLS 1.3"] {
+		findings = append(findings, SecurityFinding{SeverityLow, "Does not support TLS 1.3", 5})
+	}
+
+	insecure := make(map[string]bool)
+	for _, s := range tls.InsecureCipherSuites() {
+		insecure[s.Name] = true
+	}
+	var weak, noPFS []string
+	for _, c := range ciphers {
+		switch {
+		case strings.Contains(c, "_RC4_") || strings.Contains(c, "_3DES_") || strings.HasSuffix(c, "_CBC_SHA256"):
+			weak = append(weak, c)
+		case strings.HasPrefix(c, "TLS_RSA_"):
+			// RSA key exchange is also listed as insecure by crypto/tls
+			noPFS = append(noPFS, c)
+		case insecure[c]:
+			weak = append(weak, c)
+		}
+	}
+	if len(weak) > 0 {
+		findings = append(findings, SecurityFinding{SeverityHigh, "Accepts weak cipher suites: " + strings.Join(weak, ", "), 15})
+	}
+	if len(noPFS) > 0 {
+		findings = append(findings, SecurityFinding{SeverityMedium, "Accepts cipher suites without forward secrecy: " + strings.Join(noPFS, ", "), 5})
+	}
+	return findings
+}
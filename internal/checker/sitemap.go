@@ -0,0 +1,274 @@
+package checker
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/naru-bot/upp/internal/db"
+	"golang.org/x/net/html/charset"
+)
+
+// maxSitemaps bounds how many sitemaps one check fetches when following
+// sitemap indexes.
+const maxSitemaps = 500
+
+// SitemapURL is a page listed in a sitemap.
+type SitemapURL struct {
+	Loc     string `json:"loc" xml:"loc"`
+	LastMod string `json:"lastmod,omitempty" xml:"lastmod"`
+}
+
+// SitemapUpdate is a page whose lastmod changed between two snapshots.
+type SitemapUpdate struct {
+	Loc        string `json:"loc"`
+	OldLastMod string `json:"old_lastmod"`
+	NewLastMod string `json:"new_lastmod"`
+}
+
+// SitemapDiff lists the pages added to, removed from and updated in a
+// sitemap between two snapshots.
+type SitemapDiff struct {
+	Added   []SitemapURL    `json:"added"`
+	Removed []SitemapURL    `json:"removed"`
+	Updated []SitemapUpdate `json:"updated"`
+}
+
+// HasChanges reports whether any page was added, removed or updated.
+func (d *SitemapDiff) HasChanges() bool {
+	return len(d.Added)+len(d.Removed)+len(d.Updated) > 0
+}
+
+// Summary describes the change in counts, e.g. "3 added, 1 removed".
+func (d *SitemapDiff) Summary() string {
+	var parts []string
+	if n := len(d.Added); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d added", n))
+	}
+	if n := len(d.Removed); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d removed", n))
+	}
+	if n := len(d.Updated); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d updated", n))
+	}
+	if len(parts) == 0 {
+		return "no changes"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// sitemapDoc decodes both <urlset> sitemaps and <sitemapindex> indexes.
+type sitemapDoc struct {
+	XMLName  xml.Name
+	URLs     []SitemapURL `xml:"url"`
+	Sitemaps []SitemapURL `xml:"sitemap"`
+}
+
+// ParseSitemapSnapshot decodes the URL set stored as a sitemap snapshot:
+// one "loc<TAB>lastmod" line per page.
+func ParseSitemapSnapshot(content string) map[string]string {
+	urls := make(map[string]string)
+	for _, line := range strings.Split(content, "\n") {
+		if line == "" {
+			continue
+		}
+		loc, lastmod, _ := strings.Cut(line, "\t")
+		urls[loc] = lastmod
+	}
+	return urls
+}
+
+// DiffSitemaps compares two sitemap snapshots.
+func DiffSitemaps(oldContent, newContent string) *SitemapDiff {
+	old, cur := ParseSitemapSnapshot(oldContent), ParseSitemapSnapshot(newContent)
+	d := &SitemapDiff{Added: []SitemapURL{}, Removed: []SitemapURL{}, Updated: []SitemapUpdate{}}
+	for _, loc := range sortedKeys(cur) {
+		oldMod, ok := old[loc]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, SitemapURL{Loc: loc, LastMod: cur[loc]})
+		case oldMod != cur[loc]:
+			d.Updated = append(d.Updated, SitemapUpdate{Loc: loc, OldLastMod: oldMod, NewLastMod: cur[loc]})
+		}
+	}
+	for _, loc := range sortedKeys(old) {
+		if _, ok := cur[loc]; !ok {
+			d.Removed = append(d.Removed, SitemapURL{Loc: loc, LastMod: old[loc]})
+		}
+	}
+	return d
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// checkSitemap fetches a sitemap, following sitemap indexes, and stores the
+// sorted URL set with lastmod dates as the snapshot. The target's timeout
+// bounds the whole crawl.
+func checkSitemap(ctx context.Context, target *db.Target) *Result {
+	start := time.Now()
+	result := &Result{}
+
+	timeout := time.Duration(target.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	client, err := newHTTPClient(target, timeout)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+	var headers map[string]string
+	if target.Headers != "" {
+		json.Unmarshal([]byte(target.Headers), &headers)
+	}
+
+	// The timing and status code are those of the target's own sitemap; the
+	// sitemaps it links to only contribute URLs.
+	urls := make(map[string]string)
+	queue := []string{target.URL}
+	fetched := make(map[string]bool)
+	for len(queue) > 0 {
+		loc := queue[0]
+		queue = queue[1:]
+		if fetched[loc] {
+			continue
+		}
+		if len(fetched) == maxSitemaps {
+			result.Status = "error"
+			result.Error = fmt.Sprintf("sitemap index links more than %d sitemaps", maxSitemaps)
+			return result
+		}
+		fetched[loc] = true
+
+		doc, status, timing, err := fetchSitemap(ctx, client, loc, headers, target.AcceptStatus)
+		if loc == target.URL {
+			result.StatusCode = status
+			result.Timing = timing
+			result.ResponseTime = time.Since(start)
+		}
+		if err != nil {
+			// The target is down when its own sitemap can't be fetched;
+			// anything else is a broken sitemap.
+			result.Status = "error"
+			if loc == target.URL && (status == 0 || !isAcceptedStatus(status, target.AcceptStatus)) {
+				result.Status = "down"
+			}
+			if loc != target.URL {
+				err = fmt.Errorf("sitemap %s: %w", loc, err)
+			}
+			result.Error = err.Error()
+			return result
+		}
+		for _, u := range doc.URLs {
+			if u.Loc = strings.TrimSpace(u.Loc); u.Loc != "" {
+				urls[u.Loc] = strings.TrimSpace(u.LastMod)
+			}
+		}
+		base, _ := url.Parse(loc)
+		for _, s := range doc.Sitemaps {
+			if s.Loc = strings.TrimSpace(s.Loc); s.Loc != "" {
+				queue = append(queue, resolveLink(base, s.Loc))
+			}
+		}
+	}
+	result.ResponseTime = time.Since(start)
+
+	var sb strings.Builder
+	for _, loc := range sortedKeys(urls) {
+		sb.WriteString(loc)
+		if urls[loc] != "" {
+			sb.WriteString("\t" + urls[loc])
+		}
+		sb.WriteString("\n")
+	}
+	result.Content = sb.String()
+	hash := sha256.Sum256([]byte(result.Content))
+	result.ContentHash = fmt.Sprintf("%x", hash)
+
+	snaps, err := db.GetLatestSnapshots(target.ID, 1)
+	if err == nil && len(snaps) > 0 {
+		if snaps[0].Hash != result.ContentHash {
+			result.Status = "changed"
+			result.Sitemap = DiffSitemaps(snaps[0].Content, result.Content)
+		} else {
+			result.Status = "unchanged"
+		}
+	} else {
+		result.Status = "up"
+	}
+	return result
+}
+
+// fetchSitemap downloads and decodes one sitemap or sitemap index,
+// decompressing gzipped sitemaps (sitemap.xml.gz). A status outside
+// acceptStatus (see isAcceptedStatus) is an error.
+func fetchSitemap(ctx context.Context, client *http.Client, loc string, headers map[string]string, acceptStatus string) (*sitemapDoc, int, *Timing, error) {
+	start := time.Now()
+	trace := newTimingTrace(start)
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace.clientTrace()), "GET", loc, nil)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	req.Header.Set("User-Agent", "upp/1.0")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	defer resp.Body.Close()
+
+	bodyStart := time.Now()
+	body, err := io.ReadAll(resp.Body)
+	timing := trace.result(time.Since(bodyStart))
+	if err != nil {
+		return nil, resp.StatusCode, timing, fmt.Errorf("failed to read body: %w", err)
+	}
+	if !isAcceptedStatus(resp.StatusCode, acceptStatus) {
+		return nil, resp.StatusCode, timing, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	if len(body) > 2 && body[0] == 0x1f && body[1] == 0x8b {
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, resp.StatusCode, timing, fmt.Errorf("invalid gzip: %w", err)
+		}
+		if body, err = io.ReadAll(zr); err != nil {
+			return nil, resp.StatusCode, timing, fmt.Errorf("invalid gzip: %w", err)
+		}
+	}
+
+	var doc sitemapDoc
+	dec := xml.NewDecoder(bytes.NewReader(body))
+	dec.CharsetReader = charset.NewReaderLabel
+	if err := dec.Decode(&doc); err != nil {
+		return nil, resp.StatusCode, timing, fmt.Errorf("invalid sitemap: %w", err)
+	}
+	if doc.XMLName.Local != "urlset" && doc.XMLName.Local != "sitemapindex" {
+		return nil, resp.StatusCode, timing, fmt.Errorf("invalid sitemap: unexpected <%s> document, want <urlset> or <sitemapindex>", doc.XMLName.Local)
+	}
+	return &doc, resp.StatusCode, timing, nil
+}
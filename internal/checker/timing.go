@@ -0,0 +1,93 @@
+package checker
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timing breaks the duration of an HTTP check down by phase. DNS, Connect
+// and TLS are summed over every request in a redirect chain and are zero
+// when a connection was reused; TTFB is measured from the start of the
+// check to the first byte of the final response, and Transfer is the time
+// spent reading its body.
+type Timing struct {
+	DNS      time.Duration
+	Connect  time.Duration
+	TLS      time.Duration
+	TTFB     time.Duration
+	Transfer time.Duration
+}
+
+// timingTrace collects phase timings from httptrace callbacks, which may be
+// invoked from transport goroutines.
+type timingTrace struct {
+	mu        sync.Mutex
+	start     time.Time
+	dnsStart  time.Time
+	connStart time.Time
+	tlsStart  time.Time
+	timing    Timing
+}
+
+func newTimingTrace(start time.Time) *timingTrace {
+	return &timingTrace{start: start}
+}
+
+// clientTrace returns the httptrace hooks that feed this trace.
+func (t *timingTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			t.dnsStart = time.Now()
+			t.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			if !t.dnsStart.IsZero() {
+				t.timing.DNS += time.Since(t.dnsStart)
+			}
+			t.mu.Unlock()
+		},
+		ConnectStart: func(network, addr string) {
+			t.mu.Lock()
+			t.connStart = time.Now()
+			t.mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.mu.Lock()
+			if !t.connStart.IsZero() {
+				t.timing.Connect += time.Since(t.connStart)
+			}
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			t.tlsStart = time.Now()
+			t.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			if !t.tlsStart.IsZero() {
+				t.timing.TLS += time.Since(t.tlsStart)
+			}
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.timing.TTFB = time.Since(t.start)
+			t.mu.Unlock()
+		},
+	}
+}
+
+// result returns the timings collected so far along with the given body
+// transfer time. Phases that never completed are left at zero.
+func (t *timingTrace) result(transfer time.Duration) *Timing {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	timing := t.timing
+	timing.Transfer = transfer
+	return &timing
+}
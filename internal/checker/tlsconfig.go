@@ -0,0 +1,106 @@
+package checker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// TLSFiles describes a target's client certificate and CA bundle after
+// loading, for diagnostics.
+type TLSFiles struct {
+	ClientCert *x509.Certificate // nil without a client certificate
+	CACerts    int               // certificates in the CA bundle, 0 without one
+}
+
+// IsInlinePEM reports whether a certificate reference holds PEM data rather
+// than a file path.
+func IsInlinePEM(ref string) bool {
+	return strings.HasPrefix(strings.TrimSpace(ref), "-----BEGIN")
+}
+
+// readPEM returns the PEM data of a reference that is either a file path or
+// inline PEM.
+func readPEM(ref string) ([]byte, error) {
+	if IsInlinePEM(ref) {
+		return []byte(ref), nil
+	}
+	return os.ReadFile(ref)
+}
+
+// LoadTLSFiles parses a client certificate/key pair and a CA bundle, any of
+// which may be empty. The certificate and key must be given together.
+func LoadTLSFiles(certRef, keyRef, caRef string) (*tls.Certificate, *x509.CertPool, *TLSFiles, error) {
+	info := &TLSFiles{}
+	var cert *tls.Certificate
+	if certRef != "" || keyRef != "" {
+		if certRef == "" || keyRef == "" {
+			return nil, nil, nil, fmt.Errorf("client certificate and key must be set together")
+		}
+		certPEM, err := readPEM(certRef)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("client certificate: %w", err)
+		}
+		keyPEM, err := readPEM(keyRef)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("client key: %w", err)
+		}
+		pair, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("client certificate: %w", err)
+		}
+		cert = &pair
+		info.ClientCert = pair.Leaf
+		if info.ClientCert == nil {
+			info.ClientCert, _ = x509.ParseCertificate(pair.Certificate[0])
+		}
+	}
+
+	var pool *x509.CertPool
+	if caRef != "" {
+		caPEM, err := readPEM(caRef)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("CA bundle: %w", err)
+		}
+		pool = x509.NewCertPool()
+		for rest := caPEM; ; {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			c, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("CA bundle: %w", err)
+			}
+			pool.AddCert(c)
+			info.CACerts++
+		}
+		if info.CACerts == 0 {
+			return nil, nil, nil, fmt.Errorf("CA bundle: no certificates found")
+		}
+	}
+	return cert, pool, info, nil
+}
+
+// targetTLSConfig returns the TLS configuration for a target's requests:
+// its client certificate, and its CA bundle in place of the system roots.
+func targetTLSConfig(target *db.Target) (*tls.Config, error) {
+	cert, pool, _, err := LoadTLSFiles(target.ClientCert, target.ClientKey, target.CACert)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &tls.Config{InsecureSkipVerify: target.Insecure, RootCAs: pool}
+	if cert != nil {
+		cfg.Certificates = []tls.Certificate{*cert}
+	}
+	return cfg, nil
+}
@@ -0,0 +1,372 @@
+package checker
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptrace"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/itchyny/gojq"
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// Step is one request of a transaction check. URL, header values and body
+// may reference values extracted by earlier steps as {{name}}; relative URLs
+// are resolved against the target URL.
+type Step struct {
+	Name         string            `json:"name,omitempty" yaml:"name"`
+	Method       string            `json:"method,omitempty" yaml:"method"`
+	URL          string            `json:"url" yaml:"url"`
+	Headers      map[string]string `json:"headers,omitempty" yaml:"headers"`
+	Body         string            `json:"body,omitempty" yaml:"body"`
+	AcceptStatus string            `json:"accept_status,omitempty" yaml:"accept_status"`
+	Expect       string            `json:"expect,omitempty" yaml:"expect"`
+	Extract      []Extract         `json:"extract,omitempty" yaml:"extract"`
+}
+
+// Extract captures a value from a step's response body for later steps.
+// Exactly one of JQ, Selector or Regex is set; Attr selects an attribute of
+// the element matched by Selector instead of its text, and Regex yields its
+// first capture group if it has one.
+type Extract struct {
+	Name     string `json:"name" yaml:"name"`
+	JQ       string `json:"jq,omitempty" yaml:"jq"`
+	Selector string `json:"selector,omitempty" yaml:"selector"`
+	Attr     string `json:"attr,omitempty" yaml:"attr"`
+	Regex    string `json:"regex,omitempty" yaml:"regex"`
+}
+
+// StepResult reports the outcome of one transaction step.
+type StepResult struct {
+	Name       string
+	Method     string
+	URL        string
+	StatusCode int
+	Duration   time.Duration
+	Timing     *Timing
+	Error      string
+}
+
+var stepVarPattern = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// ParseSteps decodes the steps stored with a transaction target.
+func ParseSteps(data string) ([]Step, error) {
+	var steps []Step
+	if err := json.Unmarshal([]byte(data), &steps); err != nil {
+		return nil, fmt.Errorf("invalid steps: %w", err)
+	}
+	return steps, ValidateSteps(steps)
+}
+
+// ValidateSteps checks that steps are complete and their extraction rules
+// compile, so mistakes surface when a target is added rather than on every
+// check.
+func ValidateSteps(steps []Step) error {
+	if len(steps) == 0 {
+		return fmt.Errorf("transaction has no steps")
+	}
+	for i, s := range steps {
+		label := stepLabel(i, s)
+		if s.URL == "" {
+			return fmt.Errorf("%s: url is required", label)
+		}
+		for _, ex := range s.Extract {
+			if ex.Name == "" {
+				return fmt.Errorf("%s: extract needs a name", label)
+			}
+			n := 0
+			for _, v := range []string{ex.JQ, ex.Selector, ex.Regex} {
+				if v != "" {
+					n++
+				}
+			}
+			if n != 1 {
+				return fmt.Errorf("%s: extract %q needs exactly one of jq, selector or regex", label, ex.Name)
+			}
+			if ex.JQ != "" {
+				if _, err := gojq.Parse(ex.JQ); err != nil {
+					return fmt.Errorf("%s: extract %q: invalid jq filter: %w", label, ex.Name, err)
+				}
+			}
+			if ex.Regex != "" {
+				if _, err := regexp.Compile(ex.Regex); err != nil {
+					return fmt.Errorf("%s: extract %q: invalid regex: %w", label, ex.Name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func stepLabel(i int, s Step) string {
+	if s.Name != "" {
+		return fmt.Sprintf("step %d (%s)", i+1, s.Name)
+	}
+	return fmt.Sprintf("step %d", i+1)
+}
+
+// checkTransaction runs the target's steps in order with a shared cookie
+// jar, stopping at the first failing step. The last step's body is used for
+// change detection.
+func checkTransaction(ctx context.Context, target *db.Target) *Result {
+	start := time.Now()
+	result := &Result{}
+
+	steps, err := ParseSteps(target.Steps)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+	rules, err := targetNormalize(target)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+	base, err := url.Parse(target.URL)
+	if err != nil {
+		result.Status = "error"
+		result.Error = "invalid URL: " + err.Error()
+		return result
+	}
+
+	// The timeout bounds each step; the transaction as a whole gets one
+	// timeout per step.
+	timeout := time.Duration(target.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout*time.Duration(len(steps)))
+	defer cancel()
+
+	client, err := newHTTPClient(target, timeout)
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+	client.Jar, _ = cookiejar.New(nil)
+
+	var defaultHeaders map[string]string
+	if target.Headers != "" {
+		json.Unmarshal([]byte(target.Headers), &defaultHeaders)
+	}
+
+	vars := make(map[string]string)
+	var body string
+	for i, step := range steps {
+		sr, content, err := runStep(ctx, client, base, defaultHeaders, step, vars)
+		if err != nil {
+			sr.Error = err.Error()
+		}
+		result.Steps = append(result.Steps, sr)
+		result.StatusCode = sr.StatusCode
+		if err != nil {
+			result.Status = "down"
+			result.Error = fmt.Sprintf("%s: %s", stepLabel(i, step), err)
+			result.ResponseTime = time.Since(start)
+			return result
+		}
+		body = content
+	}
+	result.ResponseTime = time.Since(start)
+
+	if target.Render != RenderRaw && IsHTML("", []byte(body)) {
+		result.RawContent = body
+		body = RenderHTML(body, target.Render)
+	}
+	result.Content = body
+	hash := sha256.Sum256([]byte(normalizeContent(body, rules)))
+	result.ContentHash = fmt.Sprintf("%x", hash)
+	if err := parseTargetValue(target, result); err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+		return result
+	}
+
+	snaps, err := db.GetLatestSnapshots(target.ID, 1)
+	if err == nil && len(snaps) > 0 {
+		if snaps[0].Hash != result.ContentHash {
+			result.Status = "changed"
+		} else {
+			result.Status = "unchanged"
+		}
+	} else {
+		result.Status = "up"
+	}
+	return result
+}
+
+// runStep sends one step's request, checks its status and expected keyword,
+// and stores its extracted values in vars. It returns the response body.
+func runStep(ctx context.Context, client *http.Client, base *url.URL, defaultHeaders map[string]string, step Step, vars map[string]string) (StepResult, string, error) {
+	start := time.Now()
+	sr := StepResult{Name: step.Name, Method: strings.ToUpper(step.Method)}
+	if sr.Method == "" {
+		sr.Method = "GET"
+	}
+
+	rawURL, err := expandVars(step.URL, vars)
+	if err != nil {
+		return sr, "", err
+	}
+	ref, err := url.Parse(rawURL)
+	if err != nil {
+		return sr, "", fmt.Errorf("invalid url: %w", err)
+	}
+	sr.URL = base.ResolveReference(ref).String()
+
+	stepBody, err := expandVars(step.Body, vars)
+	if err != nil {
+		return sr, "", err
+	}
+	var bodyReader io.Reader
+	if stepBody != "" {
+		bodyReader = strings.NewReader(stepBody)
+	}
+
+	trace := newTimingTrace(start)
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, trace.clientTrace()), sr.Method, sr.URL, bodyReader)
+	if err != nil {
+		return sr, "", err
+	}
+	req.Header.Set("User-Agent", "upp/1.0")
+	if stepBody != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for k, v := range defaultHeaders {
+		req.Header.Set(k, v)
+	}
+	for k, v := range step.Headers {
+		v, err := expandVars(v, vars)
+		if err != nil {
+			return sr, "", err
+		}
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		sr.Duration = time.Since(start)
+		sr.Error = err.Error()
+		return sr, "", err
+	}
+	defer resp.Body.Close()
+	sr.StatusCode = resp.StatusCode
+
+	bodyStart := time.Now()
+	data, err := io.ReadAll(resp.Body)
+	sr.Duration = time.Since(start)
+	sr.Timing = trace.result(time.Since(bodyStart))
+	if err != nil {
+		err = fmt.Errorf("failed to read body: %w", err)
+		sr.Error = err.Error()
+		return sr, "", err
+	}
+	content := string(data)
+
+	if !isAcceptedStatus(resp.StatusCode, step.AcceptStatus) {
+		err = fmt.Errorf("HTTP %d", resp.StatusCode)
+	} else if step.Expect != "" && !strings.Contains(content, step.Expect) {
+		err = fmt.Errorf("expected keyword %q not found", step.Expect)
+	}
+	if err != nil {
+		sr.Error = err.Error()
+		return sr, content, err
+	}
+
+	for _, ex := range step.Extract {
+		v, err := extractValue(ex, data)
+		if err != nil {
+			err = fmt.Errorf("extract %q: %w", ex.Name, err)
+			sr.Error = err.Error()
+			return sr, content, err
+		}
+		vars[ex.Name] = v
+	}
+	return sr, content, nil
+}
+
+// expandVars replaces {{name}} references with extracted values.
+func expandVars(s string, vars map[string]string) (string, error) {
+	var missing string
+	out := stepVarPattern.ReplaceAllStringFunc(s, func(m string) string {
+		name := stepVarPattern.FindStringSubmatch(m)[1]
+		v, ok := vars[name]
+		if !ok && missing == "" {
+			missing = name
+		}
+		return v
+	})
+	if missing != "" {
+		return "", fmt.Errorf("undefined variable {{%s}}", missing)
+	}
+	return out, nil
+}
+
+// extractValue applies an extraction rule to a response body.
+func extractValue(ex Extract, body []byte) (string, error) {
+	switch {
+	case ex.JQ != "":
+		var data interface{}
+		if err := json.Unmarshal(body, &data); err != nil {
+			return "", fmt.Errorf("response is not valid JSON: %w", err)
+		}
+		query, err := gojq.Parse(ex.JQ)
+		if err != nil {
+			return "", err
+		}
+		v, ok := query.Run(data).Next()
+		if !ok || v == nil {
+			return "", fmt.Errorf("no match")
+		}
+		if err, isErr := v.(error); isErr {
+			return "", err
+		}
+		if s, isStr := v.(string); isStr {
+			return s, nil
+		}
+		b, _ := json.Marshal(v)
+		return string(b), nil
+	case ex.Selector != "":
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
+		if err != nil {
+			return "", err
+		}
+		sel := doc.Find(ex.Selector).First()
+		if sel.Length() == 0 {
+			return "", fmt.Errorf("no match")
+		}
+		if ex.Attr != "" {
+			v, ok := sel.Attr(ex.Attr)
+			if !ok {
+				return "", fmt.Errorf("no %s attribute", ex.Attr)
+			}
+			return v, nil
+		}
+		return strings.TrimSpace(sel.Text()), nil
+	default:
+		re, err := regexp.Compile(ex.Regex)
+		if err != nil {
+			return "", err
+		}
+		m := re.FindStringSubmatch(string(body))
+		if m == nil {
+			return "", fmt.Errorf("no match")
+		}
+		if len(m) > 1 {
+			return m[1], nil
+		}
+		return m[0], nil
+	}
+}
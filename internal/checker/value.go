@@ -0,0 +1,165 @@
+package checker
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// Number formats accepted by --numeric, named after the locales that use
+// them.
+const (
+	NumericAuto = "auto" // guess the decimal separator from the value
+	NumericEN   = "en"   // 1,234.56
+	NumericDE   = "de"   // 1.234,56
+	NumericFR   = "fr"   // 1 234,56
+	NumericCH   = "ch"   // 1'234.56
+)
+
+// numberToken matches a signed number with thousands separators (comma,
+// dot, apostrophe or space) and an optional scale suffix such as k, M or bn.
+var numberToken = regexp.MustCompile(`([-−+]?)(\d(?:[\d.,'’\x{00A0}\x{202F} ]*\d)?)(?:\s?(bn|[kKMB])\b)?`)
+
+// groupSpaces are the spaces used as thousands separators.
+const groupSpaces = " \u00a0\u202f"
+
+// ValidateNumeric checks a number format as accepted by --numeric.
+func ValidateNumeric(format string) error {
+	switch format {
+	case "", NumericAuto, NumericEN, NumericDE, NumericFR, NumericCH:
+		return nil
+	}
+	return fmt.Errorf("invalid number format %q (must be auto, en, de, fr or ch)", format)
+}
+
+// ParseValue extracts the first number from extracted content such as
+// "$1,299.00", "1.299,00 €", "12.5k followers" or "-3 °C". Currency
+// symbols and units are ignored; the suffixes k, M, B and bn scale the
+// value. format decides which of comma and dot is the decimal separator.
+func ParseValue(content, format string) (float64, error) {
+	m := numberToken.FindStringSubmatch(content)
+	if m == nil {
+		return 0, fmt.Errorf("no numeric value in %q", truncateValue(content))
+	}
+	sign, digits, suffix := m[1], m[2], m[3]
+
+	// Spaces only group thousands when followed by a full group of three
+	// digits; otherwise the number ends at the space ("3 items 2024").
+	if i := strings.IndexAny(digits, groupSpaces); i >= 0 && !spaceGrouped(digits) {
+		digits, suffix = digits[:i], ""
+	}
+
+	decimal := decimalSeparator(digits, format)
+	var sb strings.Builder
+	for _, r := range digits {
+		switch {
+		case r >= '0' && r <= '9':
+			sb.WriteRune(r)
+		case r == decimal:
+			sb.WriteRune('.')
+		}
+	}
+	v, err := strconv.ParseFloat(sb.String(), 64)
+	if err != nil {
+		return 0, fmt.Errorf("no numeric value in %q", truncateValue(content))
+	}
+	switch suffix {
+	case "k", "K":
+		v *= 1e3
+	case "M":
+		v *= 1e6
+	case "B", "bn":
+		v *= 1e9
+	}
+	if sign == "-" || sign == "−" {
+		v = -v
+	}
+	return v, nil
+}
+
+// parseTargetValue sets the result's value from its content when the
+// target tracks a numeric value.
+func parseTargetValue(target *db.Target, result *Result) error {
+	if target.Numeric == "" {
+		return nil
+	}
+	v, err := ParseValue(result.Content, target.Numeric)
+	if err != nil {
+		return err
+	}
+	result.Value = &v
+	return nil
+}
+
+// TracksValue reports whether checks of the target record a value: the
+// extracted number for numeric targets, the score for security checks.
+func TracksValue(target *db.Target) bool {
+	return target.Numeric != "" || target.Type == "security"
+}
+
+// decimalSeparator returns the rune that separates the fraction in digits,
+// or 0 when the number is whole.
+func decimalSeparator(digits, format string) rune {
+	switch format {
+	case NumericEN, NumericCH:
+		return '.'
+	case NumericDE, NumericFR:
+		return ','
+	}
+	// Guess: with both separators the last one is the decimal; a single
+	// comma is a thousands separator only when exactly three digits follow
+	// and it is the only comma.
+	lastDot, lastComma := strings.LastIndex(digits, "."), strings.LastIndex(digits, ",")
+	switch {
+	case lastDot >= 0 && lastComma >= 0:
+		if lastDot > lastComma {
+			return '.'
+		}
+		return ','
+	case lastComma >= 0:
+		if strings.Count(digits, ",") == 1 && len(digits)-lastComma-1 != 3 {
+			return ','
+		}
+		return 0
+	case lastDot >= 0:
+		if strings.Count(digits, ".") > 1 {
+			return 0
+		}
+		return '.'
+	}
+	return 0
+}
+
+// spaceGrouped reports whether every space in digits is followed by a group
+// of exactly three digits.
+func spaceGrouped(digits string) bool {
+	groups := strings.FieldsFunc(digits, func(r rune) bool {
+		return strings.ContainsRune(groupSpaces, r)
+	})
+	for _, g := range groups[1:] {
+		whole := g
+		if i := strings.IndexAny(g, ".,'’"); i >= 0 {
+			whole = g[:i]
+		}
+		if len(whole) != 3 {
+			return false
+		}
+	}
+	return true
+}
+
+func truncateValue(s string) string {
+	s = strings.TrimSpace(s)
+	if r := []rune(s); len(r) > 40 {
+		return string(r[:40]) + "…"
+	}
+	return s
+}
+
+// FormatValue formats a tracked value without trailing zeros.
+func FormatValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
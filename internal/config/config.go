@@ -4,15 +4,22 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Defaults   Defaults          `yaml:"defaults"`
-	Display    Display           `yaml:"display"`
-	Thresholds Thresholds        `yaml:"thresholds"`
-	Headers    map[string]string `yaml:"headers,omitempty"`
+	Defaults    Defaults          `yaml:"defaults"`
+	Display     Display           `yaml:"display"`
+	Thresholds  Thresholds        `yaml:"thresholds"`
+	Alerts      Alerts            `yaml:"alerts"`
+	Concurrency Concurrency       `yaml:"concurrency"`
+	Daemon      Daemon            `yaml:"daemon"`
+	Headers     map[string]string `yaml:"headers,omitempty"`
+	Proxy       string            `yaml:"proxy,omitempty"` // default proxy URL for HTTP checks without their own
+	Normalize   Normalize         `yaml:"normalize,omitempty"`
 }
 
 type Defaults struct {
@@ -23,6 +30,36 @@ type Defaults struct {
 	UserAgent   string `yaml:"user_agent"`
 }
 
+// Normalize holds content normalization rules applied before change
+// detection. The rules in config.yml apply to every target, in addition to
+// the target's own.
+type Normalize struct {
+	IgnoreRegex   []string `yaml:"ignore_regex,omitempty" json:"ignore_regex,omitempty"`     // patterns removed before hashing
+	DropSelectors []string `yaml:"drop_selectors,omitempty" json:"drop_selectors,omitempty"` // HTML elements removed before extraction
+	JQDelete      []string `yaml:"jq_delete,omitempty" json:"jq_delete,omitempty"`           // JSON paths deleted before the jq filter, e.g. .meta.generated_at
+	Whitespace    bool     `yaml:"whitespace,omitempty" json:"whitespace,omitempty"`         // collapse runs of whitespace
+	IgnoreCase    bool     `yaml:"ignore_case,omitempty" json:"ignore_case,omitempty"`       // compare case-insensitively
+	IgnoreNumbers bool     `yaml:"ignore_numbers,omitempty" json:"ignore_numbers,omitempty"` // treat changes to numbers alone as unchanged
+}
+
+// IsZero reports whether no rules are set.
+func (n Normalize) IsZero() bool {
+	return len(n.IgnoreRegex) == 0 && len(n.DropSelectors) == 0 && len(n.JQDelete) == 0 &&
+		!n.Whitespace && !n.IgnoreCase && !n.IgnoreNumbers
+}
+
+// Merge returns the rules of n combined with other's.
+func (n Normalize) Merge(other Normalize) Normalize {
+	return Normalize{
+		IgnoreRegex:   append(append([]string{}, n.IgnoreRegex...), other.IgnoreRegex...),
+		DropSelectors: append(append([]string{}, n.DropSelectors...), other.DropSelectors...),
+		JQDelete:      append(append([]string{}, n.JQDelete...), other.JQDelete...),
+		Whitespace:    n.Whitespace || other.Whitespace,
+		IgnoreCase:    n.IgnoreCase || other.IgnoreCase,
+		IgnoreNumbers: n.IgnoreNumbers || other.IgnoreNumbers,
+	}
+}
+
 type Display struct {
 	Color   bool   `yaml:"color"`
 	Format  string `yaml:"format"` // table, json, compact
@@ -33,6 +70,22 @@ type Thresholds struct {
 	SSLWarnDays int `yaml:"ssl_warn_days"` // show SSL expiry warning when days left < this (default: 30)
 }
 
+type Alerts struct {
+	FailureThreshold int `yaml:"failure_threshold"` // consecutive failed checks before alerting (default: 1)
+	ReminderInterval int `yaml:"reminder_interval"` // minutes between reminders while a target stays down (0 = never)
+}
+
+type Concurrency struct {
+	Max       int `yaml:"max"`       // checks running at once (default: 8)
+	Expensive int `yaml:"expensive"` // visual/whois/security checks running at once (default: 2)
+}
+
+type Daemon struct {
+	ShutdownGrace int    `yaml:"shutdown_grace"`       // seconds to wait for in-flight checks on shutdown (default: 30)
+	Listen        string `yaml:"listen,omitempty"`     // address of the heartbeat ping endpoint (default: 127.0.0.1:8425)
+	PublicURL     string `yaml:"public_url,omitempty"` // base URL jobs reach the endpoint at, when not the listen address
+}
+
 var current *Config
 
 func Default() *Config {
@@ -52,6 +105,16 @@ func Default() *Config {
 		Thresholds: Thresholds{
 			SSLWarnDays: 30,
 		},
+		Alerts: Alerts{
+			FailureThreshold: 1,
+		},
+		Concurrency: Concurrency{
+			Max:       8,
+			Expensive: 2,
+		},
+		Daemon: Daemon{
+			ShutdownGrace: 30,
+		},
 	}
 }
 
@@ -135,6 +198,69 @@ func (c *Config) SSLWarnDays() int {
 	return c.Thresholds.SSLWarnDays
 }
 
+// FailureThreshold returns how many consecutive failures are needed before
+// a target is considered down and an alert is sent, defaulting to 1.
+func (c *Config) FailureThreshold() int {
+	if c.Alerts.FailureThreshold <= 0 {
+		return 1
+	}
+	return c.Alerts.FailureThreshold
+}
+
+// ReminderInterval returns how often to re-alert while a target stays down.
+// Zero disables reminders.
+func (c *Config) ReminderInterval() time.Duration {
+	if c.Alerts.ReminderInterval <= 0 {
+		return 0
+	}
+	return time.Duration(c.Alerts.ReminderInterval) * time.Minute
+}
+
+// MaxConcurrency returns the global cap on concurrently running checks, defaulting to 8.
+func (c *Config) MaxConcurrency() int {
+	if c.Concurrency.Max <= 0 {
+		return 8
+	}
+	return c.Concurrency.Max
+}
+
+// ExpensiveConcurrency returns the cap on concurrently running visual, whois
+// and security checks, defaulting to 2.
+func (c *Config) ExpensiveConcurrency() int {
+	if c.Concurrency.Expensive <= 0 {
+		return 2
+	}
+	return c.Concurrency.Expensive
+}
+
+// ShutdownGrace returns how long the daemon waits for in-flight checks to
+// finish when stopping, defaulting to 30 seconds.
+func (c *Config) ShutdownGrace() time.Duration {
+	if c.Daemon.ShutdownGrace <= 0 {
+		return 30 * time.Second
+	}
+	return time.Duration(c.Daemon.ShutdownGrace) * time.Second
+}
+
+// DaemonListen returns the address the daemon serves heartbeat pings on,
+// defaulting to 127.0.0.1:8425.
+func (c *Config) DaemonListen() string {
+	if c.Daemon.Listen == "" {
+		return "127.0.0.1:8425"
+	}
+	return c.Daemon.Listen
+}
+
+// PingURL returns the URL a heartbeat target with the given token is pinged
+// at.
+func (c *Config) PingURL(token string) string {
+	base := strings.TrimRight(c.Daemon.PublicURL, "/")
+	if base == "" {
+		base = "http://" + c.DaemonListen()
+	}
+	return base + "/ping/" + token
+}
+
 func Get() *Config {
 	if current == nil {
 		return Load()
@@ -33,6 +33,24 @@ type Target struct {
 	Insecure     bool      `json:"insecure,omitempty"`      // Skip TLS verification
 	CreatedAt    time.Time `json:"created_at"`
 	Paused       bool      `json:"paused"`
+	NextRunAt    *time.Time `json:"next_run_at,omitempty"`   // Next scheduled daemon check
+	ExpiryDays   int        `json:"expiry_days,omitempty"`   // TLS: go down when the certificate expires within this many days
+	Packets      int        `json:"packets,omitempty"`       // Ping: echo requests per check
+	DegradedLoss float64    `json:"degraded_loss,omitempty"` // Ping: packet loss % at which the target is degraded (0 = never)
+	MaxLoss      float64    `json:"max_loss,omitempty"`      // Ping: packet loss % at which the target is down (0 = 100%)
+	Resolver     string     `json:"resolver,omitempty"`      // DNS: nameserver to query instead of the system resolver
+	Steps        string     `json:"steps,omitempty"`         // Transaction: JSON-encoded request steps
+	Assertions   string     `json:"assertions,omitempty"`    // HTTP: JSON-encoded list of response assertions
+	Proxy        string     `json:"proxy,omitempty"`         // HTTP/visual: proxy URL, or "direct" to bypass the default
+	ClientCert   string     `json:"client_cert,omitempty"`   // HTTP/TLS: client certificate path or inline PEM
+	ClientKey    string     `json:"client_key,omitempty"`    // HTTP/TLS: client key path or inline PEM
+	CACert       string     `json:"ca_cert,omitempty"`       // HTTP/TLS: CA bundle path or inline PEM, replacing the system roots
+	Normalize    string     `json:"normalize,omitempty"`     // HTTP: JSON-encoded content normalization rules
+	Render       string     `json:"render,omitempty"`        // HTTP: how HTML snapshots are stored: text (default), markdown or raw
+	Grace        int        `json:"grace,omitempty"`         // Heartbeat: seconds a ping may be late before the target is down (0 = 60)
+	Numeric      string     `json:"numeric,omitempty"`       // Number format of the extracted value to track (auto, en, de, fr, ch); empty = not numeric
+	WatchHeaders string     `json:"watch_headers,omitempty"` // HTTP: response headers to snapshot: "all" (minus volatile ones) or a comma-separated list
+	FeedHash     string     `json:"-"`                       // Feed: content hash of the last readable fetch; empty until the feed has a baseline
 }
 
 type CheckResult struct {
@@ -43,25 +61,112 @@ type CheckResult struct {
 	ResponseTime int64     `json:"response_time_ms"`
 	ContentHash  string    `json:"content_hash,omitempty"`
 	Error        string    `json:"error,omitempty"`
+	Body         string    `json:"body,omitempty"`   // Request body of a heartbeat ping
+	Timing       *Timing   `json:"timing,omitempty"` // HTTP phase timings, nil for other check types
 	CheckedAt    time.Time `json:"checked_at"`
 }
 
+// Timing is the per-phase breakdown of an HTTP check, in milliseconds.
+type Timing struct {
+	DNS      int64 `json:"dns_ms"`
+	Connect  int64 `json:"connect_ms"`
+	TLS      int64 `json:"tls_ms"`
+	TTFB     int64 `json:"ttfb_ms"`
+	Transfer int64 `json:"transfer_ms"`
+}
+
 type Snapshot struct {
-	ID        int64     `json:"id"`
-	TargetID  int64     `json:"target_id"`
-	Content   string    `json:"content"`
-	Hash      string    `json:"hash"`
-	CreatedAt time.Time `json:"created_at"`
+	ID         int64     `json:"id"`
+	TargetID   int64     `json:"target_id"`
+	Content    string    `json:"content"`
+	Hash       string    `json:"hash"`
+	Raw        string    `json:"raw,omitempty"`         // response body, when Content is rendered from HTML
+	Headers    string    `json:"headers,omitempty"`     // watched response headers, one "Name: value" per line
+	HeaderHash string    `json:"header_hash,omitempty"` // hash of Headers, empty when headers are not watched
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// FeedItem is an entry of a feed target, recorded once it has been seen.
+type FeedItem struct {
+	GUID      string `json:"guid"`
+	Title     string `json:"title"`
+	Link      string `json:"link,omitempty"`
+	Published string `json:"published,omitempty"` // RFC 3339 when the feed's date could be parsed
 }
 
 type NotifyConfig struct {
 	ID       int64  `json:"id"`
 	Name     string `json:"name"`
-	Type     string `json:"type"` // webhook, command, slack, telegram, discord
+	Type    string `json:"type"`   // webhook, command, slack, telegram, discord, email, pagerduty, opsgenie
 	Config   string `json:"config"` // JSON config
 	Enabled  bool   `json:"enabled"`
 }
 
+// AlertState tracks the notification state of a target between checks so that
+// alerts fire on transitions rather than on every failing check.
+type AlertState struct {
+	TargetID     int64      `json:"target_id"`
+	State        string     `json:"state"` // up, down, degraded
+	Since        time.Time  `json:"since"`
+	Failures     int        `json:"consecutive_failures"`
+	LastNotified *time.Time `json:"last_notified_at,omitempty"`
+	SSLNotified  *time.Time `json:"ssl_notified_expiry,omitempty"` // expiry of the certificate last notified as expiring
+}
+
+// Delivery statuses of a notification.
+const (
+	DeliverySending    = "sending" // claimed by a sender until next_retry_at
+	DeliveryPending    = "pending" // failed, waiting for a retry
+	DeliverySent       = "sent"
+	DeliveryFailed     = "failed"     // gave up after the last retry
+	DeliverySuperseded = "superseded" // dropped for a newer event that was sent
+)
+
+// NotifyDelivery is a notification sent, or to be retried, to one channel.
+// Payload is the JSON-encoded event, resent as is by retries.
+type NotifyDelivery struct {
+	ID            int64      `json:"id"`
+	ChannelID     int64      `json:"channel_id"`
+	Channel       string     `json:"channel"`
+	ChannelType   string     `json:"-"`
+	ChannelConfig string     `json:"-"`
+	TargetID      int64      `json:"target_id,omitempty"`
+	Event         string     `json:"event"`
+	Payload       string     `json:"-"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	NextRetryAt   *time.Time `json:"next_retry_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// NotifyAttempt is one attempt at a delivery. StatusCode is 0 when the
+// channel doesn't use HTTP or no response was received.
+type NotifyAttempt struct {
+	ID          int64     `json:"id"`
+	DeliveryID  int64     `json:"delivery_id"`
+	Channel     string    `json:"channel"`
+	Target      string    `json:"target,omitempty"`
+	Event       string    `json:"event"`
+	StatusCode  int       `json:"status_code,omitempty"`
+	Error       string    `json:"error,omitempty"`
+	Warning     string    `json:"warning,omitempty"` // problem that didn't stop the delivery, e.g. a failing template
+	LatencyMs   int64     `json:"latency_ms"`
+	AttemptedAt time.Time `json:"attempted_at"`
+	Delivery    string    `json:"delivery_status"` // status of the delivery after all attempts so far
+}
+
+// NotifyRoute sends the notifications of one target, or of every target
+// with a tag, to a channel. A route with neither applies to every target.
+type NotifyRoute struct {
+	ID        int64  `json:"id"`
+	ChannelID int64  `json:"channel_id"`
+	Channel   string `json:"channel"`
+	TargetID  int64  `json:"target_id,omitempty"`
+	Target    string `json:"target,omitempty"`
+	Tag       string `json:"tag,omitempty"`
+	Events    string `json:"events,omitempty"` // comma-separated event types; empty = all
+}
+
 var db *sql.DB
 
 func GetDBPath() string {
@@ -141,7 +246,8 @@ func Init() error {
 
 func InitWithPath(path string) error {
 	var err error
-	db, err = sql.Open("sqlite", path+"?_journal_mode=WAL")
+	// busy_timeout lets concurrent checks wait for the write lock instead of failing
+	db, err = sql.Open("sqlite", path+"?_journal_mode=WAL&_pragma=busy_timeout(5000)")
 	if err != nil {
 		return err
 	}
@@ -168,6 +274,24 @@ func InitWithPath(path string) error {
 		insecure INTEGER DEFAULT 0,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		paused INTEGER DEFAULT 0,
+		next_run_at DATETIME,
+		expiry_days INTEGER DEFAULT 0,
+		packets INTEGER DEFAULT 0,
+		degraded_loss REAL DEFAULT 0,
+		max_loss REAL DEFAULT 0,
+		resolver TEXT DEFAULT '',
+		steps TEXT DEFAULT '',
+		assertions TEXT DEFAULT '',
+		proxy TEXT DEFAULT '',
+		client_cert TEXT DEFAULT '',
+		client_key TEXT DEFAULT '',
+		ca_cert TEXT DEFAULT '',
+		normalize TEXT DEFAULT '',
+		render TEXT DEFAULT '',
+		grace INTEGER DEFAULT 0,
+		numeric_format TEXT DEFAULT '',
+		watch_headers TEXT DEFAULT '',
+		feed_hash TEXT DEFAULT '',
 		UNIQUE(url, type, selector)
 	);
 
@@ -180,6 +304,12 @@ func InitWithPath(path string) error {
 		content_hash TEXT DEFAULT '',
 		error TEXT DEFAULT '',
 		checked_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		dns_ms INTEGER,
+		connect_ms INTEGER,
+		tls_ms INTEGER,
+		ttfb_ms INTEGER,
+		transfer_ms INTEGER,
+		body TEXT DEFAULT '',
 		FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
 	);
 
@@ -188,6 +318,9 @@ func InitWithPath(path string) error {
 		target_id INTEGER NOT NULL,
 		content TEXT NOT NULL,
 		hash TEXT NOT NULL,
+		raw TEXT DEFAULT '',
+		headers TEXT DEFAULT '',
+		header_hash TEXT DEFAULT '',
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
 	);
@@ -207,6 +340,82 @@ func InitWithPath(path string) error {
 		FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
 	);
 
+	CREATE TABLE IF NOT EXISTS alert_states (
+		target_id INTEGER PRIMARY KEY,
+		state TEXT NOT NULL DEFAULT 'up',
+		since DATETIME DEFAULT CURRENT_TIMESTAMP,
+		failures INTEGER DEFAULT 0,
+		last_notified_at DATETIME,
+		ssl_notified_expiry DATETIME,
+		FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS feed_items (
+		target_id INTEGER NOT NULL,
+		guid TEXT NOT NULL,
+		title TEXT DEFAULT '',
+		link TEXT DEFAULT '',
+		published TEXT DEFAULT '',
+		initial INTEGER DEFAULT 0,
+		seen_at DATETIME NOT NULL,
+		PRIMARY KEY (target_id, guid),
+		FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS heartbeats (
+		target_id INTEGER PRIMARY KEY,
+		last_ping_at DATETIME,
+		last_status TEXT DEFAULT '',
+		last_error TEXT DEFAULT '',
+		started_at DATETIME,
+		FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS target_values (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		target_id INTEGER NOT NULL,
+		value REAL NOT NULL,
+		recorded_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (target_id) REFERENCES targets(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_values_target ON target_values(target_id, recorded_at);
+
+	CREATE TABLE IF NOT EXISTS notify_routes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		channel_id INTEGER NOT NULL,
+		target_id INTEGER DEFAULT 0,
+		tag TEXT DEFAULT '',
+		events TEXT DEFAULT '',
+		FOREIGN KEY (channel_id) REFERENCES notify_configs(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS notify_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		channel_id INTEGER NOT NULL,
+		target_id INTEGER DEFAULT 0,
+		event TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INTEGER DEFAULT 0,
+		next_retry_at DATETIME,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS notify_attempts (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		delivery_id INTEGER NOT NULL,
+		status_code INTEGER DEFAULT 0,
+		error TEXT DEFAULT '',
+		warning TEXT DEFAULT '',
+		latency_ms INTEGER DEFAULT 0,
+		attempted_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		FOREIGN KEY (delivery_id) REFERENCES notify_deliveries(id) ON DELETE CASCADE
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_deliveries_status ON notify_deliveries(status, next_retry_at);
+	CREATE INDEX IF NOT EXISTS idx_attempts_delivery ON notify_attempts(delivery_id);
+
 	CREATE INDEX IF NOT EXISTS idx_results_target ON check_results(target_id, checked_at);
 	CREATE INDEX IF NOT EXISTS idx_snapshots_target ON snapshots(target_id, created_at);
 	CREATE INDEX IF NOT EXISTS idx_target_tags ON target_tags(tag);
@@ -269,6 +478,136 @@ func InitWithPath(path string) error {
 		return err
 	}
 
+	// Migration: Add next_run_at column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN next_run_at DATETIME")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add expiry_days column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN expiry_days INTEGER DEFAULT 0")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add ping loss columns
+	for _, col := range []string{"packets INTEGER DEFAULT 0", "degraded_loss REAL DEFAULT 0", "max_loss REAL DEFAULT 0"} {
+		_, err = db.Exec("ALTER TABLE targets ADD COLUMN " + col)
+		if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			return err
+		}
+	}
+
+	// Migration: Add resolver column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN resolver TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add steps column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN steps TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add assertions column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN assertions TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add proxy column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN proxy TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add normalize column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN normalize TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add render column. Targets that predate it keep storing
+	// the raw body, so upgrading doesn't rewrite their snapshots and report
+	// a change; only new targets render HTML as text by default
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN render TEXT DEFAULT ''")
+	if err == nil {
+		db.Exec("UPDATE targets SET render = 'raw'")
+	} else if !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add grace column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN grace INTEGER DEFAULT 0")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add numeric_format column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN numeric_format TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add heartbeat ping body column to check_results
+	_, err = db.Exec("ALTER TABLE check_results ADD COLUMN body TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add raw body column to snapshots
+	_, err = db.Exec("ALTER TABLE snapshots ADD COLUMN raw TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add feed baseline column
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN feed_hash TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add watched header columns
+	_, err = db.Exec("ALTER TABLE targets ADD COLUMN watch_headers TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+	for _, col := range []string{"headers", "header_hash"} {
+		_, err = db.Exec("ALTER TABLE snapshots ADD COLUMN " + col + " TEXT DEFAULT ''")
+		if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			return err
+		}
+	}
+
+	// Migration: Add client certificate and CA bundle columns
+	for _, col := range []string{"client_cert", "client_key", "ca_cert"} {
+		_, err = db.Exec("ALTER TABLE targets ADD COLUMN " + col + " TEXT DEFAULT ''")
+		if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			return err
+		}
+	}
+
+	// Migration: Add warning column to notify_attempts
+	_, err = db.Exec("ALTER TABLE notify_attempts ADD COLUMN warning TEXT DEFAULT ''")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add ssl_notified_expiry column to alert_states
+	_, err = db.Exec("ALTER TABLE alert_states ADD COLUMN ssl_notified_expiry DATETIME")
+	if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+		return err
+	}
+
+	// Migration: Add HTTP timing columns to check_results
+	for _, col := range []string{"dns_ms", "connect_ms", "tls_ms", "ttfb_ms", "transfer_ms"} {
+		_, err = db.Exec("ALTER TABLE check_results ADD COLUMN " + col + " INTEGER")
+		if err != nil && !strings.Contains(err.Error(), "duplicate column name") {
+			return err
+		}
+	}
+
 	// Migration: Update unique constraint from (url, selector) to (url, type, selector)
 	// SQLite can't alter constraints, so we recreate the table
 	var tableSql string
@@ -295,9 +634,29 @@ func InitWithPath(path string) error {
 			no_follow INTEGER DEFAULT 0,
 			accept_status TEXT DEFAULT '',
 			insecure INTEGER DEFAULT 0,
+			next_run_at DATETIME,
+			expiry_days INTEGER DEFAULT 0,
+			packets INTEGER DEFAULT 0,
+			degraded_loss REAL DEFAULT 0,
+			max_loss REAL DEFAULT 0,
+			resolver TEXT DEFAULT '',
+			steps TEXT DEFAULT '',
+			assertions TEXT DEFAULT '',
+			proxy TEXT DEFAULT '',
+			client_cert TEXT DEFAULT '',
+			client_key TEXT DEFAULT '',
+			ca_cert TEXT DEFAULT '',
+			normalize TEXT DEFAULT '',
+			render TEXT DEFAULT '',
+			grace INTEGER DEFAULT 0,
+			numeric_format TEXT DEFAULT '',
+			watch_headers TEXT DEFAULT '',
+			feed_hash TEXT DEFAULT '',
 			UNIQUE(url, type, selector)
 		)`)
-		db.Exec(`INSERT INTO targets_new SELECT * FROM targets`)
+		// Name the columns: the ALTER migrations above add them in a
+		// different order than targets_new declares them
+		db.Exec("INSERT INTO targets_new (" + targetColumns + ") SELECT " + targetColumns + " FROM targets")
 		db.Exec(`DROP TABLE targets`)
 		db.Exec(`ALTER TABLE targets_new RENAME TO targets`)
 	}
@@ -317,6 +676,22 @@ type AddTargetOpts struct {
 	NoFollow     bool
 	AcceptStatus string
 	Insecure     bool
+	ExpiryDays   int
+	Packets      int
+	DegradedLoss float64
+	MaxLoss      float64
+	Resolver     string
+	Steps        string
+	Assertions   string
+	Proxy        string
+	ClientCert   string
+	ClientKey    string
+	CACert       string
+	Normalize    string
+	Render       string
+	Grace        int
+	Numeric      string
+	WatchHeaders string
 }
 
 func AddTarget(name, url, typ string, interval int, selector, headers, expect string, timeout, retries int, threshold float64, opts AddTargetOpts) (*Target, error) {
@@ -341,14 +716,48 @@ func AddTarget(name, url, typ string, interval int, selector, headers, expect st
 		insecure = 1
 	}
 	res, err := db.Exec(
-		"INSERT INTO targets (name, url, type, interval_seconds, selector, headers, expect, timeout, retries, threshold, trigger_rule, jq_filter, method, body, no_follow, accept_status, insecure) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		name, url, typ, interval, selector, headers, expect, timeout, retries, threshold, opts.TriggerRule, opts.JQFilter, opts.Method, opts.Body, noFollow, opts.AcceptStatus, insecure,
+		"INSERT INTO targets (name, url, type, interval_seconds, selector, headers, expect, timeout, retries, threshold, trigger_rule, jq_filter, method, body, no_follow, accept_status, insecure, expiry_days, packets, degraded_loss, max_loss, resolver, steps, assertions, proxy, client_cert, client_key, ca_cert, normalize, render, grace, numeric_format, watch_headers) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		name, url, typ, interval, selector, headers, expect, timeout, retries, threshold, opts.TriggerRule, opts.JQFilter, opts.Method, opts.Body, noFollow, opts.AcceptStatus, insecure, opts.ExpiryDays, opts.Packets, opts.DegradedLoss, opts.MaxLoss, opts.Resolver, opts.Steps, opts.Assertions, opts.Proxy, opts.ClientCert, opts.ClientKey, opts.CACert, opts.Normalize, opts.Render, opts.Grace, opts.Numeric, opts.WatchHeaders,
 	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to add target (may already exist): %w", err)
 	}
 	id, _ := res.LastInsertId()
-	return &Target{ID: id, Name: name, URL: url, Type: typ, Interval: interval, Selector: selector, Headers: headers, Expect: expect, Timeout: timeout, Retries: retries, Threshold: threshold, TriggerRule: opts.TriggerRule, JQFilter: opts.JQFilter, Method: opts.Method, Body: opts.Body, NoFollow: opts.NoFollow, AcceptStatus: opts.AcceptStatus, Insecure: opts.Insecure, CreatedAt: time.Now()}, nil
+	return &Target{ID: id, Name: name, URL: url, Type: typ, Interval: interval, Selector: selector, Headers: headers, Expect: expect, Timeout: timeout, Retries: retries, Threshold: threshold, TriggerRule: opts.TriggerRule, JQFilter: opts.JQFilter, Method: opts.Method, Body: opts.Body, NoFollow: opts.NoFollow, AcceptStatus: opts.AcceptStatus, Insecure: opts.Insecure, ExpiryDays: opts.ExpiryDays, Packets: opts.Packets, DegradedLoss: opts.DegradedLoss, MaxLoss: opts.MaxLoss, Resolver: opts.Resolver, Steps: opts.Steps, Assertions: opts.Assertions, Proxy: opts.Proxy, ClientCert: opts.ClientCert, ClientKey: opts.ClientKey, CACert: opts.CACert, Normalize: opts.Normalize, Render: opts.Render, Grace: opts.Grace, Numeric: opts.Numeric, WatchHeaders: opts.WatchHeaders, CreatedAt: time.Now()}, nil
+}
+
+// targetColumns is the column list scanned by scanTarget, in order.
+const targetColumns = "id, name, url, type, interval_seconds, selector, headers, expect, timeout, retries, threshold, trigger_rule, jq_filter, method, body, no_follow, accept_status, insecure, created_at, paused, next_run_at, expiry_days, packets, degraded_loss, max_loss, resolver, steps, assertions, proxy, client_cert, client_key, ca_cert, normalize, render, grace, numeric_format, watch_headers, feed_hash"
+
+// prefixedTargetColumns returns targetColumns qualified with a table alias.
+func prefixedTargetColumns(alias string) string {
+	cols := strings.Split(targetColumns, ", ")
+	for i, c := range cols {
+		cols[i] = alias + "." + c
+	}
+	return strings.Join(cols, ", ")
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanTarget reads a row selected with targetColumns into a Target.
+func scanTarget(row scanner) (*Target, error) {
+	var t Target
+	var paused, noFollow, insecure int
+	var nextRun sql.NullTime
+	err := row.Scan(&t.ID, &t.Name, &t.URL, &t.Type, &t.Interval, &t.Selector, &t.Headers, &t.Expect, &t.Timeout, &t.Retries, &t.Threshold, &t.TriggerRule, &t.JQFilter, &t.Method, &t.Body, &noFollow, &t.AcceptStatus, &insecure, &t.CreatedAt, &paused, &nextRun, &t.ExpiryDays, &t.Packets, &t.DegradedLoss, &t.MaxLoss, &t.Resolver, &t.Steps, &t.Assertions, &t.Proxy, &t.ClientCert, &t.ClientKey, &t.CACert, &t.Normalize, &t.Render, &t.Grace, &t.Numeric, &t.WatchHeaders, &t.FeedHash)
+	if err != nil {
+		return nil, err
+	}
+	if nextRun.Valid {
+		t.NextRunAt = &nextRun.Time
+	}
+	t.Paused = paused == 1
+	t.NoFollow = noFollow == 1
+	t.Insecure = insecure == 1
+	return &t, nil
 }
 
 func RemoveTarget(identifier string) error {
@@ -365,7 +774,7 @@ func RemoveTarget(identifier string) error {
 }
 
 func ListTargets() ([]Target, error) {
-	rows, err := db.Query("SELECT id, name, url, type, interval_seconds, selector, headers, expect, timeout, retries, threshold, trigger_rule, jq_filter, method, body, no_follow, accept_status, insecure, created_at, paused FROM targets ORDER BY id")
+	rows, err := db.Query("SELECT " + targetColumns + " FROM targets ORDER BY id")
 	if err != nil {
 		return nil, err
 	}
@@ -373,34 +782,24 @@ func ListTargets() ([]Target, error) {
 
 	var targets []Target
 	for rows.Next() {
-		var t Target
-		var paused, noFollow, insecure int
-		err := rows.Scan(&t.ID, &t.Name, &t.URL, &t.Type, &t.Interval, &t.Selector, &t.Headers, &t.Expect, &t.Timeout, &t.Retries, &t.Threshold, &t.TriggerRule, &t.JQFilter, &t.Method, &t.Body, &noFollow, &t.AcceptStatus, &insecure, &t.CreatedAt, &paused)
+		t, err := scanTarget(rows)
 		if err != nil {
 			return nil, err
 		}
-		t.Paused = paused == 1
-		t.NoFollow = noFollow == 1
-		t.Insecure = insecure == 1
-		targets = append(targets, t)
+		targets = append(targets, *t)
 	}
 	return targets, nil
 }
 
 func GetTarget(identifier string) (*Target, error) {
-	var t Target
-	var paused, noFollow, insecure int
-	err := db.QueryRow(
-		"SELECT id, name, url, type, interval_seconds, selector, headers, expect, timeout, retries, threshold, trigger_rule, jq_filter, method, body, no_follow, accept_status, insecure, created_at, paused FROM targets WHERE name = ? OR url = ? OR id = ?",
+	t, err := scanTarget(db.QueryRow(
+		"SELECT "+targetColumns+" FROM targets WHERE name = ? OR url = ? OR id = ?",
 		identifier, identifier, identifier,
-	).Scan(&t.ID, &t.Name, &t.URL, &t.Type, &t.Interval, &t.Selector, &t.Headers, &t.Expect, &t.Timeout, &t.Retries, &t.Threshold, &t.TriggerRule, &t.JQFilter, &t.Method, &t.Body, &noFollow, &t.AcceptStatus, &insecure, &t.CreatedAt, &paused)
+	))
 	if err != nil {
 		return nil, fmt.Errorf("target not found: %s", identifier)
 	}
-	t.Paused = paused == 1
-	t.NoFollow = noFollow == 1
-	t.Insecure = insecure == 1
-	return &t, nil
+	return t, nil
 }
 
 func UpdateTarget(t *Target) error {
@@ -413,8 +812,8 @@ func UpdateTarget(t *Target) error {
 		insecure = 1
 	}
 	res, err := db.Exec(
-		`UPDATE targets SET name=?, url=?, type=?, interval_seconds=?, selector=?, headers=?, expect=?, timeout=?, retries=?, threshold=?, trigger_rule=?, jq_filter=?, method=?, body=?, no_follow=?, accept_status=?, insecure=? WHERE id=?`,
-		t.Name, t.URL, t.Type, t.Interval, t.Selector, t.Headers, t.Expect, t.Timeout, t.Retries, t.Threshold, t.TriggerRule, t.JQFilter, t.Method, t.Body, noFollow, t.AcceptStatus, insecure, t.ID,
+		`UPDATE targets SET name=?, url=?, type=?, interval_seconds=?, selector=?, headers=?, expect=?, timeout=?, retries=?, threshold=?, trigger_rule=?, jq_filter=?, method=?, body=?, no_follow=?, accept_status=?, insecure=?, expiry_days=?, packets=?, degraded_loss=?, max_loss=?, resolver=?, steps=?, assertions=?, proxy=?, client_cert=?, client_key=?, ca_cert=?, normalize=?, render=?, grace=?, numeric_format=?, watch_headers=? WHERE id=?`,
+		t.Name, t.URL, t.Type, t.Interval, t.Selector, t.Headers, t.Expect, t.Timeout, t.Retries, t.Threshold, t.TriggerRule, t.JQFilter, t.Method, t.Body, noFollow, t.AcceptStatus, insecure, t.ExpiryDays, t.Packets, t.DegradedLoss, t.MaxLoss, t.Resolver, t.Steps, t.Assertions, t.Proxy, t.ClientCert, t.ClientKey, t.CACert, t.Normalize, t.Render, t.Grace, t.Numeric, t.WatchHeaders, t.ID,
 	)
 	if err != nil {
 		return err
@@ -426,17 +825,37 @@ func UpdateTarget(t *Target) error {
 	return nil
 }
 
+// SetFeedHash records the content hash of a feed target's last readable
+// fetch, marking its items as a baseline for later checks.
+func SetFeedHash(targetID int64, hash string) error {
+	_, err := db.Exec("UPDATE targets SET feed_hash = ? WHERE id = ?", hash, targetID)
+	return err
+}
+
+// SetNextRun persists when the daemon should next check a target.
+func SetNextRun(targetID int64, at time.Time) error {
+	_, err := db.Exec("UPDATE targets SET next_run_at = ? WHERE id = ?", at, targetID)
+	return err
+}
+
 func SaveCheckResult(r *CheckResult) error {
+	// Timing columns stay NULL for checks without a phase breakdown
+	var dns, connect, tlsMs, ttfb, transfer interface{}
+	if r.Timing != nil {
+		dns, connect, tlsMs, ttfb, transfer = r.Timing.DNS, r.Timing.Connect, r.Timing.TLS, r.Timing.TTFB, r.Timing.Transfer
+	}
 	_, err := db.Exec(
-		"INSERT INTO check_results (target_id, status, status_code, response_time_ms, content_hash, error) VALUES (?, ?, ?, ?, ?, ?)",
-		r.TargetID, r.Status, r.StatusCode, r.ResponseTime, r.ContentHash, r.Error,
+		`INSERT INTO check_results (target_id, status, status_code, response_time_ms, content_hash, error, dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms, body)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		r.TargetID, r.Status, r.StatusCode, r.ResponseTime, r.ContentHash, r.Error, dns, connect, tlsMs, ttfb, transfer, r.Body,
 	)
 	return err
 }
 
 func GetCheckHistory(targetID int64, limit int) ([]CheckResult, error) {
 	rows, err := db.Query(
-		"SELECT id, target_id, status, status_code, response_time_ms, content_hash, error, checked_at FROM check_results WHERE target_id = ? ORDER BY checked_at DESC LIMIT ?",
+		`SELECT id, target_id, status, status_code, response_time_ms, content_hash, error, checked_at, dns_ms, connect_ms, tls_ms, ttfb_ms, transfer_ms, body
+		FROM check_results WHERE target_id = ? ORDER BY checked_at DESC LIMIT ?`,
 		targetID, limit,
 	)
 	if err != nil {
@@ -447,26 +866,37 @@ func GetCheckHistory(targetID int64, limit int) ([]CheckResult, error) {
 	var results []CheckResult
 	for rows.Next() {
 		var r CheckResult
-		err := rows.Scan(&r.ID, &r.TargetID, &r.Status, &r.StatusCode, &r.ResponseTime, &r.ContentHash, &r.Error, &r.CheckedAt)
+		var dns, connect, tlsMs, ttfb, transfer sql.NullInt64
+		err := rows.Scan(&r.ID, &r.TargetID, &r.Status, &r.StatusCode, &r.ResponseTime, &r.ContentHash, &r.Error, &r.CheckedAt,
+			&dns, &connect, &tlsMs, &ttfb, &transfer, &r.Body)
 		if err != nil {
 			return nil, err
 		}
+		if ttfb.Valid {
+			r.Timing = &Timing{
+				DNS:      dns.Int64,
+				Connect:  connect.Int64,
+				TLS:      tlsMs.Int64,
+				TTFB:     ttfb.Int64,
+				Transfer: transfer.Int64,
+			}
+		}
 		results = append(results, r)
 	}
 	return results, nil
 }
 
-func SaveSnapshot(targetID int64, content, hash string) error {
+func SaveSnapshot(targetID int64, content, raw, hash, headers, headerHash string) error {
 	_, err := db.Exec(
-		"INSERT INTO snapshots (target_id, content, hash) VALUES (?, ?, ?)",
-		targetID, content, hash,
+		"INSERT INTO snapshots (target_id, content, hash, raw, headers, header_hash) VALUES (?, ?, ?, ?, ?, ?)",
+		targetID, content, hash, raw, headers, headerHash,
 	)
 	return err
 }
 
 func GetLatestSnapshots(targetID int64, limit int) ([]Snapshot, error) {
 	rows, err := db.Query(
-		"SELECT id, target_id, content, hash, created_at FROM snapshots WHERE target_id = ? ORDER BY created_at DESC LIMIT ?",
+		"SELECT id, target_id, content, hash, raw, headers, header_hash, created_at FROM snapshots WHERE target_id = ? ORDER BY created_at DESC LIMIT ?",
 		targetID, limit,
 	)
 	if err != nil {
@@ -477,7 +907,7 @@ func GetLatestSnapshots(targetID int64, limit int) ([]Snapshot, error) {
 	var snaps []Snapshot
 	for rows.Next() {
 		var s Snapshot
-		err := rows.Scan(&s.ID, &s.TargetID, &s.Content, &s.Hash, &s.CreatedAt)
+		err := rows.Scan(&s.ID, &s.TargetID, &s.Content, &s.Hash, &s.Raw, &s.Headers, &s.HeaderHash, &s.CreatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -486,9 +916,76 @@ func GetLatestSnapshots(targetID int64, limit int) ([]Snapshot, error) {
 	return snaps, nil
 }
 
+// GetFeedItemGUIDs returns the GUIDs of every item seen in a feed target.
+func GetFeedItemGUIDs(targetID int64) (map[string]bool, error) {
+	rows, err := db.Query("SELECT guid FROM feed_items WHERE target_id = ?", targetID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	seen := make(map[string]bool)
+	for rows.Next() {
+		var guid string
+		if err := rows.Scan(&guid); err != nil {
+			return nil, err
+		}
+		seen[guid] = true
+	}
+	return seen, rows.Err()
+}
+
+// SaveFeedItems records feed items as seen; items seen before are left
+// alone. Initial marks the items found on a feed's first check, which are
+// not reported as new.
+func SaveFeedItems(targetID int64, items []FeedItem, initial bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	seenAt := time.Now().UTC().Truncate(time.Second)
+	for _, it := range items {
+		_, err := tx.Exec(
+			"INSERT OR IGNORE INTO feed_items (target_id, guid, title, link, published, initial, seen_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			targetID, it.GUID, it.Title, it.Link, it.Published, initial, seenAt,
+		)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetNewFeedItems returns the items a feed target most recently reported as
+// new, and when they were seen.
+func GetNewFeedItems(targetID int64) ([]FeedItem, time.Time, error) {
+	rows, err := db.Query(
+		`SELECT guid, title, link, published, seen_at FROM feed_items
+		WHERE target_id = ? AND initial = 0 AND seen_at = (SELECT MAX(seen_at) FROM feed_items WHERE target_id = ? AND initial = 0)
+		ORDER BY published DESC, rowid`,
+		targetID, targetID,
+	)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	defer rows.Close()
+
+	var items []FeedItem
+	var seenAt time.Time
+	for rows.Next() {
+		var it FeedItem
+		if err := rows.Scan(&it.GUID, &it.Title, &it.Link, &it.Published, &seenAt); err != nil {
+			return nil, time.Time{}, err
+		}
+		items = append(items, it)
+	}
+	return items, seenAt, rows.Err()
+}
+
 func GetUptimeStats(targetID int64, since time.Time) (total int, up int, avgResponseMs float64, err error) {
 	err = db.QueryRow(
-		`SELECT COUNT(*), COALESCE(SUM(CASE WHEN status='up' OR status='unchanged' OR status='changed' THEN 1 ELSE 0 END), 0), COALESCE(AVG(response_time_ms), 0)
+		`SELECT COUNT(*), COALESCE(SUM(CASE WHEN status IN ('up', 'unchanged', 'changed', 'degraded') THEN 1 ELSE 0 END), 0), COALESCE(AVG(response_time_ms), 0)
 		FROM check_results WHERE target_id = ? AND checked_at >= ?`,
 		targetID, since,
 	).Scan(&total, &up, &avgResponseMs)
@@ -546,9 +1043,206 @@ func RemoveNotifyConfig(identifier string) error {
 	if n == 0 {
 		return fmt.Errorf("notification config not found: %s", identifier)
 	}
+	// Routes to the channel go with it, and its pending deliveries give up
+	_, err = db.Exec("DELETE FROM notify_routes WHERE channel_id NOT IN (SELECT id FROM notify_configs)")
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec("UPDATE notify_deliveries SET status = ?, next_retry_at = NULL WHERE status IN (?, ?) AND channel_id NOT IN (SELECT id FROM notify_configs)", DeliveryFailed, DeliveryPending, DeliverySending)
+	return err
+}
+
+// GetNotifyConfig looks up a notification channel by name or ID.
+func GetNotifyConfig(identifier string) (*NotifyConfig, error) {
+	var c NotifyConfig
+	var enabled int
+	err := db.QueryRow("SELECT id, name, type, config, enabled FROM notify_configs WHERE name = ? OR id = ? ORDER BY id LIMIT 1", identifier, identifier).
+		Scan(&c.ID, &c.Name, &c.Type, &c.Config, &enabled)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("notification config not found: %s", identifier)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.Enabled = enabled == 1
+	return &c, nil
+}
+
+// AddNotifyRoute routes a target's notifications (targetID), those of every
+// target with a tag, or with neither those of every target, to a channel.
+// events is a comma-separated list of event types; empty routes all of them.
+func AddNotifyRoute(channelID, targetID int64, tag, events string) (int64, error) {
+	res, err := db.Exec("INSERT INTO notify_routes (channel_id, target_id, tag, events) VALUES (?, ?, ?, ?)", channelID, targetID, tag, events)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// ListNotifyRoutes returns every route with its channel and target names.
+// Routes of removed targets are left out.
+func ListNotifyRoutes() ([]NotifyRoute, error) {
+	rows, err := db.Query(
+		`SELECT r.id, r.channel_id, c.name, r.target_id, COALESCE(t.name, ''), r.tag, r.events
+		FROM notify_routes r
+		INNER JOIN notify_configs c ON c.id = r.channel_id
+		LEFT JOIN targets t ON t.id = r.target_id
+		WHERE r.target_id = 0 OR t.id IS NOT NULL
+		ORDER BY r.id`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var routes []NotifyRoute
+	for rows.Next() {
+		var r NotifyRoute
+		if err := rows.Scan(&r.ID, &r.ChannelID, &r.Channel, &r.TargetID, &r.Target, &r.Tag, &r.Events); err != nil {
+			return nil, err
+		}
+		routes = append(routes, r)
+	}
+	return routes, rows.Err()
+}
+
+// RemoveNotifyRoute deletes a route by ID.
+func RemoveNotifyRoute(id int64) error {
+	res, err := db.Exec("DELETE FROM notify_routes WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return fmt.Errorf("notification route not found: %d", id)
+	}
 	return nil
 }
 
+// GetAlertState returns the persisted alert state for a target.
+// A target that has never been checked starts out "up" with no failures.
+func GetAlertState(targetID int64) (*AlertState, error) {
+	s := &AlertState{TargetID: targetID}
+	var lastNotified, sslNotified sql.NullTime
+	err := db.QueryRow(
+		"SELECT state, since, failures, last_notified_at, ssl_notified_expiry FROM alert_states WHERE target_id = ?",
+		targetID,
+	).Scan(&s.State, &s.Since, &s.Failures, &lastNotified, &sslNotified)
+	if err == sql.ErrNoRows {
+		s.State = "up"
+		s.Since = time.Now()
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lastNotified.Valid {
+		s.LastNotified = &lastNotified.Time
+	}
+	if sslNotified.Valid {
+		s.SSLNotified = &sslNotified.Time
+	}
+	return s, nil
+}
+
+func SaveAlertState(s *AlertState) error {
+	_, err := db.Exec(
+		"INSERT OR REPLACE INTO alert_states (target_id, state, since, failures, last_notified_at, ssl_notified_expiry) VALUES (?, ?, ?, ?, ?, ?)",
+		s.TargetID, s.State, s.Since, s.Failures, s.LastNotified, s.SSLNotified,
+	)
+	return err
+}
+
+// Value is a numeric value extracted by a check.
+type Value struct {
+	Value      float64   `json:"value"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// SaveValue appends a value to a target's time series.
+func SaveValue(targetID int64, v float64) error {
+	_, err := db.Exec("INSERT INTO target_values (target_id, value) VALUES (?, ?)", targetID, v)
+	return err
+}
+
+// GetValues returns a target's values recorded since the given time, newest
+// first. A limit of 0 returns them all.
+func GetValues(targetID int64, since time.Time, limit int) ([]Value, error) {
+	query := "SELECT value, recorded_at FROM target_values WHERE target_id = ? AND recorded_at >= ? ORDER BY recorded_at DESC, id DESC"
+	args := []interface{}{targetID, since.UTC()}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values []Value
+	for rows.Next() {
+		var v Value
+		if err := rows.Scan(&v.Value, &v.RecordedAt); err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+	}
+	return values, rows.Err()
+}
+
+// Heartbeat is the last ping received for a heartbeat target.
+type Heartbeat struct {
+	LastPingAt *time.Time // nil until the first ping
+	LastStatus string     // up or down
+	LastError  string
+	StartedAt  *time.Time // set by a start ping, cleared by the next ping
+}
+
+// GetHeartbeat returns the ping state of a heartbeat target.
+func GetHeartbeat(targetID int64) (*Heartbeat, error) {
+	h := &Heartbeat{}
+	var lastPing, started sql.NullTime
+	err := db.QueryRow(
+		"SELECT last_ping_at, last_status, last_error, started_at FROM heartbeats WHERE target_id = ?",
+		targetID,
+	).Scan(&lastPing, &h.LastStatus, &h.LastError, &started)
+	if err == sql.ErrNoRows {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if lastPing.Valid {
+		h.LastPingAt = &lastPing.Time
+	}
+	if started.Valid {
+		h.StartedAt = &started.Time
+	}
+	return h, nil
+}
+
+// StartHeartbeat records that a heartbeat target's job has started.
+func StartHeartbeat(targetID int64, at time.Time) error {
+	_, err := db.Exec(
+		`INSERT INTO heartbeats (target_id, started_at) VALUES (?, ?)
+		ON CONFLICT(target_id) DO UPDATE SET started_at = excluded.started_at`,
+		targetID, at,
+	)
+	return err
+}
+
+// RecordHeartbeat records a success (status "up") or failure ("down") ping.
+func RecordHeartbeat(targetID int64, status, errMsg string, at time.Time) error {
+	_, err := db.Exec(
+		`INSERT INTO heartbeats (target_id, last_ping_at, last_status, last_error, started_at) VALUES (?, ?, ?, ?, NULL)
+		ON CONFLICT(target_id) DO UPDATE SET last_ping_at = excluded.last_ping_at, last_status = excluded.last_status,
+			last_error = excluded.last_error, started_at = NULL`,
+		targetID, at, status, errMsg,
+	)
+	return err
+}
+
 // Tag operations
 
 func AddTags(targetID int64, tags []string) error {
@@ -627,7 +1321,7 @@ func GetTagMap() (map[int64][]string, error) {
 // ListTargetsByTag returns targets that have the specified tag.
 func ListTargetsByTag(tag string) ([]Target, error) {
 	rows, err := db.Query(
-		`SELECT t.id, t.name, t.url, t.type, t.interval_seconds, t.selector, t.headers, t.expect, t.timeout, t.retries, t.threshold, t.trigger_rule, t.jq_filter, t.method, t.body, t.no_follow, t.accept_status, t.insecure, t.created_at, t.paused
+		`SELECT `+prefixedTargetColumns("t")+`
 		FROM targets t INNER JOIN target_tags tt ON t.id = tt.target_id
 		WHERE tt.tag = ? ORDER BY t.id`, tag,
 	)
@@ -637,16 +1331,161 @@ func ListTargetsByTag(tag string) ([]Target, error) {
 	defer rows.Close()
 	var targets []Target
 	for rows.Next() {
-		var t Target
-		var paused, noFollow, insecure int
-		err := rows.Scan(&t.ID, &t.Name, &t.URL, &t.Type, &t.Interval, &t.Selector, &t.Headers, &t.Expect, &t.Timeout, &t.Retries, &t.Threshold, &t.TriggerRule, &t.JQFilter, &t.Method, &t.Body, &noFollow, &t.AcceptStatus, &insecure, &t.CreatedAt, &paused)
+		t, err := scanTarget(rows)
 		if err != nil {
 			return nil, err
 		}
-		t.Paused = paused == 1
-		t.NoFollow = noFollow == 1
-		t.Insecure = insecure == 1
-		targets = append(targets, t)
+		targets = append(targets, *t)
 	}
 	return targets, nil
 }
+
+// CreateDelivery queues a notification for a channel before its first
+// attempt.
+// CreateDelivery queues a delivery already claimed by the caller until
+// lease expires, so no retry picks it up while it is first sent.
+func CreateDelivery(channelID, targetID int64, event, payload string, lease time.Time) (int64, error) {
+	res, err := db.Exec(
+		"INSERT INTO notify_deliveries (channel_id, target_id, event, payload, status, next_retry_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		channelID, targetID, event, payload, DeliverySending, lease, time.Now(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// RecordDeliveryAttempt logs an attempt at a delivery and moves the delivery
+// to status, due for retry at nextRetry when it is pending.
+func RecordDeliveryAttempt(deliveryID int64, statusCode int, errMsg, warning string, latencyMs int64, status string, nextRetry *time.Time) error {
+	now := time.Now()
+	if _, err := db.Exec(
+		"INSERT INTO notify_attempts (delivery_id, status_code, error, warning, latency_ms, attempted_at) VALUES (?, ?, ?, ?, ?, ?)",
+		deliveryID, statusCode, errMsg, warning, latencyMs, now,
+	); err != nil {
+		return err
+	}
+	_, err := db.Exec(
+		"UPDATE notify_deliveries SET status = ?, attempts = attempts + 1, next_retry_at = ? WHERE id = ?",
+		status, nextRetry, deliveryID,
+	)
+	return err
+}
+
+// ListDueDeliveries returns the pending deliveries due for a retry by now,
+// and those whose sender's claim expired, oldest first. Deliveries to
+// disabled or removed channels wait.
+func ListDueDeliveries(now time.Time) ([]NotifyDelivery, error) {
+	return queryDeliveries("d.status IN (?, ?) AND d.next_retry_at <= ?", DeliveryPending, DeliverySending, now)
+}
+
+// ListUndelivered returns every delivery not sent yet, pending or given up,
+// oldest first, leaving out those a sender is working on.
+func ListUndelivered(now time.Time) ([]NotifyDelivery, error) {
+	return queryDeliveries("(d.status IN (?, ?) OR (d.status = ? AND d.next_retry_at <= ?))", DeliveryPending, DeliveryFailed, DeliverySending, now)
+}
+
+// ClaimDelivery marks a listed delivery as being sent until lease expires.
+// It returns false when the delivery changed since it was listed, e.g.
+// because another process claimed or sent it.
+func ClaimDelivery(d NotifyDelivery, lease time.Time) (bool, error) {
+	res, err := db.Exec(
+		"UPDATE notify_deliveries SET status = ?, next_retry_at = ? WHERE id = ? AND status = ? AND attempts = ?",
+		DeliverySending, lease, d.ID, d.Status, d.Attempts,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+// SupersedeDeliveries drops the undelivered deliveries of the given events
+// to a channel for a target that are older than deliveryID, once a newer
+// one was sent. Deliveries a sender is working on are left alone.
+func SupersedeDeliveries(channelID, targetID, deliveryID int64, events []string) error {
+	if len(events) == 0 {
+		return nil
+	}
+	args := []interface{}{DeliverySuperseded, channelID, targetID, deliveryID, DeliveryPending, DeliveryFailed}
+	for _, e := range events {
+		args = append(args, e)
+	}
+	_, err := db.Exec(
+		"UPDATE notify_deliveries SET status = ?, next_retry_at = NULL WHERE channel_id = ? AND target_id = ? AND id < ? AND status IN (?, ?) AND event IN (?"+strings.Repeat(", ?", len(events)-1)+")",
+		args...,
+	)
+	return err
+}
+
+func queryDeliveries(where string, args ...interface{}) ([]NotifyDelivery, error) {
+	rows, err := db.Query(
+		`SELECT d.id, d.channel_id, c.name, c.type, c.config, d.target_id, d.event, d.payload, d.status, d.attempts, d.next_retry_at, d.created_at
+		FROM notify_deliveries d INNER JOIN notify_configs c ON c.id = d.channel_id
+		WHERE c.enabled = 1 AND `+where+` ORDER BY d.id`, args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []NotifyDelivery
+	for rows.Next() {
+		var d NotifyDelivery
+		var next sql.NullTime
+		if err := rows.Scan(&d.ID, &d.ChannelID, &d.Channel, &d.ChannelType, &d.ChannelConfig, &d.TargetID, &d.Event, &d.Payload, &d.Status, &d.Attempts, &next, &d.CreatedAt); err != nil {
+			return nil, err
+		}
+		if next.Valid {
+			d.NextRetryAt = &next.Time
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// CountPendingDeliveries returns how many deliveries wait for a retry.
+func CountPendingDeliveries() (int, error) {
+	var n int
+	err := db.QueryRow("SELECT COUNT(*) FROM notify_deliveries WHERE status = ?", DeliveryPending).Scan(&n)
+	return n, err
+}
+
+// ListDeliveryAttempts returns the latest delivery attempts, newest first,
+// optionally only those of one target (targetID > 0) or only failed ones.
+func ListDeliveryAttempts(targetID int64, failedOnly bool, limit int) ([]NotifyAttempt, error) {
+	query := `SELECT a.id, a.delivery_id, COALESCE(c.name, ''), COALESCE(t.name, ''), d.event, a.status_code, a.error, a.warning, a.latency_ms, a.attempted_at, d.status
+		FROM notify_attempts a
+		INNER JOIN notify_deliveries d ON d.id = a.delivery_id
+		LEFT JOIN notify_configs c ON c.id = d.channel_id
+		LEFT JOIN targets t ON t.id = d.target_id
+		WHERE 1 = 1`
+	var args []interface{}
+	if targetID > 0 {
+		query += " AND d.target_id = ?"
+		args = append(args, targetID)
+	}
+	if failedOnly {
+		query += " AND a.error != ''"
+	}
+	query += " ORDER BY a.id DESC"
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attempts []NotifyAttempt
+	for rows.Next() {
+		var a NotifyAttempt
+		if err := rows.Scan(&a.ID, &a.DeliveryID, &a.Channel, &a.Target, &a.Event, &a.StatusCode, &a.Error, &a.Warning, &a.LatencyMs, &a.AttemptedAt, &a.Delivery); err != nil {
+			return nil, err
+		}
+		attempts = append(attempts, a)
+	}
+	return attempts, rows.Err()
+}
@@ -0,0 +1,237 @@
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/naru-bot/upp/internal/checker"
+	"github.com/naru-bot/upp/internal/db"
+)
+
+// DoneFunc receives the result of a finished check. Callbacks are invoked one
+// at a time, so they may write to the database or stdout without extra locking.
+// Every scheduled check calls its callback exactly once; a check dropped or
+// aborted by shutdown reports a result with Canceled set.
+type DoneFunc func(t db.Target, result *checker.Result)
+
+// Engine runs checks concurrently with a global concurrency cap, a separate
+// cap for expensive check types, and at most one in-flight check per target.
+type Engine struct {
+	global    chan struct{}
+	expensive chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu       sync.Mutex
+	idle     *sync.Cond // signaled when a target's check finishes or draining starts
+	inFlight map[int64]bool
+	draining bool
+	aborted  bool
+
+	doneMu sync.Mutex
+	wg     sync.WaitGroup
+}
+
+// New creates an engine that runs at most concurrency checks at once, of
+// which at most expensive may be visual, whois or security checks.
+func New(concurrency, expensive int) *Engine {
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	if expensive <= 0 || expensive > concurrency {
+		expensive = concurrency
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Engine{
+		global:    make(chan struct{}, concurrency),
+		expensive: make(chan struct{}, expensive),
+		ctx:       ctx,
+		cancel:    cancel,
+		inFlight:  make(map[int64]bool),
+	}
+	e.idle = sync.NewCond(&e.mu)
+	return e
+}
+
+// isExpensive reports whether a check type is slow or resource-hungry enough
+// to be limited separately (headless browser launches, WHOIS rate limits,
+// the dozens of handshakes and probes of a security check).
+func isExpensive(typ string) bool {
+	switch typ {
+	case "visual", "whois", "security":
+		return true
+	}
+	return false
+}
+
+// Submit schedules a check for the target and returns immediately. It returns
+// false without scheduling anything if a check for the target is already in
+// flight or the engine is shutting down.
+func (e *Engine) Submit(t db.Target, done DoneFunc) bool {
+	e.mu.Lock()
+	if e.inFlight[t.ID] || e.draining {
+		e.mu.Unlock()
+		return false
+	}
+	e.inFlight[t.ID] = true
+	e.mu.Unlock()
+
+	e.wg.Add(1)
+	go func() {
+		defer e.wg.Done()
+		defer e.release(t.ID)
+
+		// Take the expensive slot first so a queued visual check doesn't
+		// hold a global slot that cheap checks could be using.
+		expensive := isExpensive(t.Type)
+		if expensive && !e.acquire(e.expensive) {
+			e.finish(t, canceled(), done)
+			return
+		}
+		if !e.acquire(e.global) {
+			if expensive {
+				<-e.expensive
+			}
+			e.finish(t, canceled(), done)
+			return
+		}
+		result := checker.CheckContext(e.ctx, &t)
+		<-e.global
+		if expensive {
+			<-e.expensive
+		}
+
+		// A check aborted by shutdown says nothing about the target
+		if e.ctx.Err() != nil {
+			result = canceled()
+		}
+		e.finish(t, result, done)
+	}()
+	return true
+}
+
+// canceled is the result of a check that was dropped or aborted.
+func canceled() *checker.Result {
+	return &checker.Result{Status: "error", Error: "check aborted", Canceled: true}
+}
+
+// finish hands a check's result to its callback.
+func (e *Engine) finish(t db.Target, result *checker.Result, done DoneFunc) {
+	if done == nil {
+		return
+	}
+	e.doneMu.Lock()
+	defer e.doneMu.Unlock()
+	done(t, result)
+}
+
+// release marks the target's check as finished.
+func (e *Engine) release(id int64) {
+	e.mu.Lock()
+	delete(e.inFlight, id)
+	e.idle.Broadcast()
+	e.mu.Unlock()
+}
+
+// Exclusive runs fn for a target in place of a check, for results obtained
+// outside the engine such as heartbeat pings: it waits for the target's
+// check in flight to finish, keeps new ones from starting meanwhile and
+// runs fn one at a time with the callbacks of checks. It returns false
+// without running fn if the engine is shutting down.
+func (e *Engine) Exclusive(id int64, fn func()) bool {
+	e.mu.Lock()
+	for e.inFlight[id] && !e.draining {
+		e.idle.Wait()
+	}
+	if e.draining {
+		e.mu.Unlock()
+		return false
+	}
+	e.inFlight[id] = true
+	e.wg.Add(1)
+	e.mu.Unlock()
+	defer e.wg.Done()
+	defer e.release(id)
+
+	e.doneMu.Lock()
+	defer e.doneMu.Unlock()
+	fn()
+	return true
+}
+
+// acquire takes a slot from sem, giving up if the engine is aborted or
+// started draining while the check was queued.
+func (e *Engine) acquire(sem chan struct{}) bool {
+	select {
+	case sem <- struct{}{}:
+	case <-e.ctx.Done():
+		return false
+	}
+	e.mu.Lock()
+	draining := e.draining
+	e.mu.Unlock()
+	if draining {
+		<-sem
+		return false
+	}
+	return true
+}
+
+// InFlight reports whether a check for the target is currently running or queued.
+func (e *Engine) InFlight(id int64) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.inFlight[id]
+}
+
+// Run checks all targets and blocks until every check has finished.
+func (e *Engine) Run(targets []db.Target, done DoneFunc) {
+	for _, t := range targets {
+		e.Submit(t, done)
+	}
+	e.Wait()
+}
+
+// Wait blocks until all submitted checks have finished.
+func (e *Engine) Wait() {
+	e.wg.Wait()
+}
+
+// Abort cancels all running checks and drops queued ones, whose callbacks
+// get a canceled result.
+func (e *Engine) Abort() {
+	e.mu.Lock()
+	e.aborted = true
+	e.mu.Unlock()
+	e.cancel()
+}
+
+// Shutdown stops accepting new checks, drops queued ones and waits up to
+// grace for running checks to finish before aborting them. It reports
+// whether everything finished within the grace period without being
+// aborted, by it or by a call to Abort meanwhile.
+func (e *Engine) Shutdown(grace time.Duration) bool {
+	e.mu.Lock()
+	e.draining = true
+	e.idle.Broadcast()
+	e.mu.Unlock()
+
+	finished := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		e.mu.Lock()
+		defer e.mu.Unlock()
+		return !e.aborted
+	case <-time.After(grace):
+		e.Abort()
+		<-finished
+		return false
+	}
+}
@@ -0,0 +1,289 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SMTP connection security of an email channel.
+const (
+	SecuritySTARTTLS = "starttls" // upgrade a plain connection, required by the server
+	SecurityTLS      = "tls"      // implicit TLS, usually port 465
+	SecurityNone     = "none"     // plain text, for local relays and test sinks
+)
+
+// smtpTimeout bounds a whole SMTP conversation.
+const smtpTimeout = 30 * time.Second
+
+// smtpRootCAs verifies SMTP servers' certificates; nil uses the system's
+// roots. Tests set it to trust their own server.
+var smtpRootCAs *x509.CertPool
+
+type emailConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"`     // default: 465 with implicit TLS, otherwise 587
+	Security string   `json:"security"` // starttls, tls or none; default: tls on port 465, otherwise starttls
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// parseEmailConfig decodes and validates an email channel's config,
+// filling in the port and security defaults.
+func parseEmailConfig(configJSON string) (*emailConfig, error) {
+	var cfg emailConfig
+	if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Host == "" {
+		return nil, errors.New("email config needs a host")
+	}
+	if cfg.Security == "" {
+		cfg.Security = SecuritySTARTTLS
+		if cfg.Port == 465 {
+			cfg.Security = SecurityTLS
+		}
+	}
+	switch cfg.Security {
+	case SecuritySTARTTLS, SecurityNone:
+		if cfg.Port == 0 {
+			cfg.Port = 587
+		}
+	case SecurityTLS:
+		if cfg.Port == 0 {
+			cfg.Port = 465
+		}
+	default:
+		return nil, fmt.Errorf("invalid email security %q (must be starttls, tls or none)", cfg.Security)
+	}
+	if _, err := mail.ParseAddress(cfg.From); err != nil {
+		return nil, fmt.Errorf("invalid email from address %q: %w", cfg.From, err)
+	}
+	if len(cfg.To) == 0 {
+		return nil, errors.New("email config needs at least one to address")
+	}
+	for _, to := range cfg.To {
+		if _, err := mail.ParseAddress(to); err != nil {
+			return nil, fmt.Errorf("invalid email to address %q: %w", to, err)
+		}
+	}
+	return &cfg, nil
+}
+
+// sendEmail mails the event as a multipart text and HTML message. A
+// rejection by the server returns its SMTP reply code.
+func sendEmail(configJSON string, event Event, r *Rendered) (int, error) {
+	cfg, err := parseEmailConfig(configJSON)
+	if err != nil {
+		return 0, err
+	}
+	err = sendSMTP(cfg, buildEmail(cfg, event, r, time.Now()))
+	var reply *textproto.Error
+	if errors.As(err, &reply) {
+		return reply.Code, err
+	}
+	return 0, err
+}
+
+func sendSMTP(cfg *emailConfig, msg []byte) error {
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var conn net.Conn
+	var err error
+	if cfg.Security == SecurityTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: cfg.Host, RootCAs: smtpRootCAs})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+
+	c, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if cfg.Security == SecuritySTARTTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not support STARTTLS (set \"security\" to \"tls\" or \"none\")", addr)
+		}
+		if err := c.StartTLS(&tls.Config{ServerName: cfg.Host, RootCAs: smtpRootCAs}); err != nil {
+			return err
+		}
+	}
+	if cfg.Username != "" {
+		// PlainAuth refuses to send the password over an unencrypted
+		// connection to anything but localhost
+		if err := c.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return err
+		}
+	}
+
+	from, _ := mail.ParseAddress(cfg.From)
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	for _, to := range cfg.To {
+		rcpt, _ := mail.ParseAddress(to)
+		if err := c.Rcpt(rcpt.Address); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(msg); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// buildEmail assembles the full message, headers included, from the
+// event's rendered subject and HTML body.
+func buildEmail(cfg *emailConfig, event Event, r *Rendered, now time.Time) []byte {
+	id := make([]byte, 12)
+	rand.Read(id)
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	headers := []string{
+		"From: " + cfg.From,
+		"To: " + strings.Join(cfg.To, ", "),
+		"Subject: " + mime.QEncoding.Encode("utf-8", r.Subject),
+		"Date: " + now.Format(time.RFC1123Z),
+		"Message-ID: <" + hex.EncodeToString(id) + "@upp>",
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + mw.Boundary(),
+	}
+	buf.WriteString(strings.Join(headers, "\r\n") + "\r\n\r\n")
+
+	for _, part := range []struct{ typ, body string }{
+		{"text/plain", emailText(event)},
+		{"text/html", r.HTML},
+	} {
+		pw, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.typ + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		qw := quotedprintable.NewWriter(pw)
+		qw.Write([]byte(part.body))
+		qw.Close()
+	}
+	mw.Close()
+	return buf.Bytes()
+}
+
+// emailText is the plain-text body: the rendered message, the event's
+// details and the diff excerpt.
+func emailText(event Event) string {
+	var sb strings.Builder
+	sb.WriteString(event.Message + "\n\n")
+	fmt.Fprintf(&sb, "Target: %s\n", event.Target)
+	if event.URL != "" {
+		fmt.Fprintf(&sb, "URL: %s\n", event.URL)
+	}
+	fmt.Fprintf(&sb, "Event: %s\n", event.Event)
+	fmt.Fprintf(&sb, "Status: %s\n", event.Status)
+	if event.Error != "" {
+		fmt.Fprintf(&sb, "Error: %s\n", event.Error)
+	}
+	fmt.Fprintf(&sb, "Time: %s\n", event.Time)
+	if event.Diff != "" {
+		sb.WriteString("\nChanges:\n" + event.Diff)
+	}
+	return sb.String()
+}
+
+// emailData is what the HTML body is rendered against: the event, with
+// its rendered message, plus the subject and the colored lines of the diff.
+type emailData struct {
+	Event
+	Subject   string
+	DiffLines []diffLine
+}
+
+type diffLine struct {
+	Text  string
+	Color string
+}
+
+// diffLines colors the lines of a diff excerpt for the HTML body.
+func diffLines(excerpt string) []diffLine {
+	var lines []diffLine
+	for _, l := range strings.Split(strings.TrimRight(excerpt, "\n"), "\n") {
+		if l == "" {
+			continue
+		}
+		color := "#666"
+		switch l[0] {
+		case '+':
+			color = "#1a7f37"
+		case '-':
+			color = "#cf222e"
+		}
+		lines = append(lines, diffLine{Text: l, Color: color})
+	}
+	return lines
+}
+
+var emailHTML = template.Must(template.New("email").Funcs(template.FuncMap(templateFuncs)).Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: -apple-system, Segoe UI, Helvetica, Arial, sans-serif; font-size: 14px; color: #1f2328;">
+<h2 style="margin: 0 0 12px;">{{.Subject}}</h2>
+<p style="white-space: pre-wrap;">{{.Message}}</p>
+<table cellpadding="4" style="border-collapse: collapse;">
+<tr><th align="left">Target</th><td>{{.Target}}</td></tr>
+{{- if .URL}}
+<tr><th align="left">URL</th><td>{{.URL}}</td></tr>
+{{- end}}
+<tr><th align="left">Event</th><td>{{.Event.Event}}</td></tr>
+<tr><th align="left">Status</th><td>{{.Status}}</td></tr>
+{{- if .Error}}
+<tr><th align="left">Error</th><td style="color: #cf222e;">{{.Error}}</td></tr>
+{{- end}}
+<tr><th align="left">Time</th><td>{{.Time}}</td></tr>
+</table>
+{{- if .Items}}
+<ul>
+{{- range .Items}}
+<li>{{if .Link}}<a href="{{.Link}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}{{if .Published}} ({{datetime .Published}}){{end}}</li>
+{{- end}}
+</ul>
+{{- end}}
+{{- if .DiffLines}}
+<h3>Changes</h3>
+<pre style="background: #f6f8fa; padding: 8px;">
+{{- range .DiffLines}}
+<span style="color: {{.Color}};">{{.Text}}</span>
+{{- end}}
+</pre>
+{{- end}}
+</body>
+</html>
+`))
@@ -0,0 +1,246 @@
+package notify
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"net/textproto"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpStub is an SMTP server on a local listener that accepts one
+// connection and records the commands and message it receives.
+type smtpStub struct {
+	ln   net.Listener
+	tls  *tls.Config // offers STARTTLS when set
+	done chan struct{}
+
+	// Written by the server; read them after wait
+	cmds []string
+	data string
+}
+
+func startSMTPStub(t *testing.T, tlsConfig *tls.Config) *smtpStub {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpStub{ln: ln, tls: tlsConfig, done: make(chan struct{})}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		defer close(s.done)
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		s.serve(conn)
+	}()
+	return s
+}
+
+func (s *smtpStub) port() int {
+	return s.ln.Addr().(*net.TCPAddr).Port
+}
+
+// wait waits for the client to hang up.
+func (s *smtpStub) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("SMTP session did not end")
+	}
+}
+
+func (s *smtpStub) serve(conn net.Conn) {
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 stub ESMTP")
+	secure := false
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, _, _ := strings.Cut(line, " ")
+		cmd = strings.ToUpper(cmd)
+		s.cmds = append(s.cmds, cmd)
+		switch cmd {
+		case "EHLO":
+			ext := []string{"stub", "8BITMIME"}
+			if s.tls != nil && !secure {
+				ext = append(ext, "STARTTLS")
+			}
+			if secure {
+				ext = append(ext, "AUTH PLAIN")
+			}
+			for i, e := range ext {
+				sep := "-"
+				if i == len(ext)-1 {
+					sep = " "
+				}
+				tp.PrintfLine("250%s%s", sep, e)
+			}
+		case "STARTTLS":
+			tp.PrintfLine("220 ready")
+			tc := tls.Server(conn, s.tls)
+			if err := tc.Handshake(); err != nil {
+				return
+			}
+			conn, secure = tc, true
+			tp = textproto.NewConn(tc)
+		case "AUTH":
+			tp.PrintfLine("235 ok")
+		case "MAIL", "RCPT":
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.data = string(data)
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func emailChannel(port int, security, extra string) string {
+	return fmt.Sprintf(`{"host":"127.0.0.1","port":%d,"security":%q,"from":"upp@example.com","to":["ops@example.com"]%s}`, port, security, extra)
+}
+
+// trustStub makes sendSMTP trust the certificate of an httptest TLS server,
+// which is valid for 127.0.0.1, and returns its TLS config.
+func trustStub(t *testing.T) *tls.Config {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	smtpRootCAs = pool
+	t.Cleanup(func() { smtpRootCAs = nil })
+	return &tls.Config{Certificates: srv.TLS.Certificates}
+}
+
+func TestSendEmailNone(t *testing.T) {
+	s := startSMTPStub(t, nil)
+	if _, err := Send("email", emailChannel(s.port(), SecurityNone, ""), SampleEvent("down")); err != nil {
+		t.Fatal(err)
+	}
+	s.wait(t)
+
+	if want := []string{"EHLO", "MAIL", "RCPT", "DATA", "QUIT"}; !slices.Equal(s.cmds, want) {
+		t.Errorf("commands = %v, want %v", s.cmds, want)
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(s.data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := msg.Header.Get("Subject"), "[upp] My Site is down"; got != want {
+		t.Errorf("Subject = %q, want %q", got, want)
+	}
+	typ, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || typ != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", msg.Header.Get("Content-Type"))
+	}
+
+	parts := map[string]string{}
+	var types []string
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		// NextPart decodes the quoted-printable body
+		body, err := io.ReadAll(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		types = append(types, p.Header.Get("Content-Type"))
+		parts[p.Header.Get("Content-Type")] = string(body)
+	}
+	if want := []string{"text/plain; charset=utf-8", "text/html; charset=utf-8"}; !slices.Equal(types, want) {
+		t.Fatalf("parts = %v, want %v", types, want)
+	}
+	text := parts["text/plain; charset=utf-8"]
+	for _, want := range []string{
+		"[upp] My Site (https://example.com) is down: connection timed out\n",
+		"Target: My Site\n",
+		"Status: down\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("text part lacks %q:\n%s", want, text)
+		}
+	}
+	html := parts["text/html; charset=utf-8"]
+	for _, want := range []string{
+		">[upp] My Site is down</h2>",
+		`<td style="color: #cf222e;">connection timed out</td>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("HTML part lacks %q:\n%s", want, html)
+		}
+	}
+}
+
+func TestSendEmailSTARTTLS(t *testing.T) {
+	s := startSMTPStub(t, trustStub(t))
+	config := emailChannel(s.port(), SecuritySTARTTLS, `,"username":"upp","password":"secret"`)
+	if _, err := Send("email", config, SampleEvent("down")); err != nil {
+		t.Fatal(err)
+	}
+	s.wait(t)
+
+	// The password is only sent once the connection is encrypted
+	if want := []string{"EHLO", "STARTTLS", "EHLO", "AUTH", "MAIL", "RCPT", "DATA", "QUIT"}; !slices.Equal(s.cmds, want) {
+		t.Errorf("commands = %v, want %v", s.cmds, want)
+	}
+	if !strings.Contains(s.data, "Subject: [upp] My Site is down") {
+		t.Errorf("message not delivered:\n%s", s.data)
+	}
+}
+
+func TestSendEmailSTARTTLSUnsupported(t *testing.T) {
+	s := startSMTPStub(t, nil)
+	_, err := Send("email", emailChannel(s.port(), SecuritySTARTTLS, ""), SampleEvent("down"))
+	if err == nil || !strings.Contains(err.Error(), "does not support STARTTLS") {
+		t.Fatalf("err = %v, want a missing STARTTLS error", err)
+	}
+	s.wait(t)
+	if slices.Contains(s.cmds, "MAIL") {
+		t.Errorf("sent in plain text: %v", s.cmds)
+	}
+}
+
+func TestSendEmailSTARTTLSUntrusted(t *testing.T) {
+	tlsConfig := trustStub(t)
+	smtpRootCAs = nil
+	s := startSMTPStub(t, tlsConfig)
+	if _, err := Send("email", emailChannel(s.port(), SecuritySTARTTLS, ""), SampleEvent("down")); err == nil {
+		t.Fatal("sent to a server with an untrusted certificate")
+	}
+	s.wait(t)
+	if slices.Contains(s.cmds, "MAIL") {
+		t.Errorf("sent despite the failed handshake: %v", s.cmds)
+	}
+}
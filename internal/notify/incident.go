@@ -0,0 +1,180 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Incident actions that upp events map to on incident management services.
+const (
+	ActionTrigger     = "trigger"
+	ActionAcknowledge = "acknowledge"
+	ActionResolve     = "resolve"
+	ActionIgnore      = "ignore" // the event is not sent
+)
+
+// Severities of triggered incidents, as named by PagerDuty.
+const (
+	SeverityCritical = "critical"
+	SeverityError    = "error"
+	SeverityWarning  = "warning"
+	SeverityInfo     = "info"
+)
+
+// defaultActions maps event types to incident actions. A target's outage
+// events share one incident, which its recovery resolves. Nothing resolves
+// the incident of a content change or an expiring certificate, so those
+// are opt-in.
+var defaultActions = map[string]string{
+	"down":         ActionTrigger,
+	"error":        ActionTrigger,
+	"degraded":     ActionTrigger,
+	"reminder":     ActionTrigger,
+	"recovery":     ActionResolve,
+	"changed":      ActionIgnore,
+	"ssl_expiring": ActionIgnore,
+	"test":         ActionTrigger,
+}
+
+// incidentConfig is the part of a pagerduty or opsgenie channel's config
+// that maps upp events to incidents. Actions and Severities override the
+// defaults per event type ("down", "error", "changed", ...); Severities
+// also accepts a check status.
+type incidentConfig struct {
+	APIURL      string            `json:"api_url"`
+	DedupPrefix string            `json:"dedup_prefix"` // default: upp
+	Actions     map[string]string `json:"actions"`
+	Severities  map[string]string `json:"severities"`
+}
+
+func (c *incidentConfig) validate() error {
+	for event, action := range c.Actions {
+		switch action {
+		case ActionTrigger, ActionAcknowledge, ActionResolve, ActionIgnore:
+		default:
+			return fmt.Errorf("invalid action %q for %s (must be trigger, acknowledge, resolve or ignore)", action, event)
+		}
+	}
+	for key, severity := range c.Severities {
+		switch severity {
+		case SeverityCritical, SeverityError, SeverityWarning, SeverityInfo:
+		default:
+			return fmt.Errorf("invalid severity %q for %s (must be critical, error, warning or info)", severity, key)
+		}
+	}
+	return nil
+}
+
+// eventType returns the event's type, telling a check that failed ("error")
+// apart from a target that is down.
+func eventType(event Event) string {
+	if event.Event == "down" && event.Status == "error" {
+		return "error"
+	}
+	return event.Event
+}
+
+// action returns the incident action for an event.
+func (c *incidentConfig) action(event Event) string {
+	typ := eventType(event)
+	if a, ok := c.Actions[typ]; ok {
+		return a
+	}
+	if a, ok := defaultActions[typ]; ok {
+		return a
+	}
+	return ActionTrigger
+}
+
+// severity returns the severity of the incident an event triggers: by
+// event type for changes and expiring certificates, otherwise by status.
+func (c *incidentConfig) severity(event Event) string {
+	typ := eventType(event)
+	if s, ok := c.Severities[typ]; ok {
+		return s
+	}
+	if s, ok := c.Severities[event.Status]; ok {
+		return s
+	}
+	switch typ {
+	case "changed", "test":
+		return SeverityInfo
+	case "ssl_expiring":
+		return SeverityWarning
+	}
+	switch event.Status {
+	case "down":
+		return SeverityCritical
+	case "error":
+		return SeverityError
+	case "degraded":
+		return SeverityWarning
+	}
+	return SeverityInfo
+}
+
+// dedupKey returns the incident key of an event: one per target for
+// outages, with separate keys for content changes and expiring
+// certificates so that a recovery only resolves the outage.
+func (c *incidentConfig) dedupKey(event Event) string {
+	prefix := c.DedupPrefix
+	if prefix == "" {
+		prefix = "upp"
+	}
+	switch event.Event {
+	case "test":
+		return prefix + "-test"
+	case "changed":
+		return fmt.Sprintf("%s-%d-changed", prefix, event.TargetID)
+	case "ssl_expiring":
+		return fmt.Sprintf("%s-%d-ssl", prefix, event.TargetID)
+	}
+	return fmt.Sprintf("%s-%d", prefix, event.TargetID)
+}
+
+// apiURL joins the configured API base URL, or the service's default, with
+// a path.
+func (c *incidentConfig) apiURL(defaultBase, path string) string {
+	base := c.APIURL
+	if base == "" {
+		base = defaultBase
+	}
+	return strings.TrimRight(base, "/") + path
+}
+
+// incidentSummary is the event's message without the "[upp] " prefix,
+// cut to max characters.
+func incidentSummary(event Event, max int) string {
+	s, _, _ := strings.Cut(strings.TrimPrefix(event.Message, "[upp] "), "\n")
+	if r := []rune(s); len(r) > max {
+		s = string(r[:max-1]) + "…"
+	}
+	return s
+}
+
+// incidentDetails collects the event's fields for an incident's details.
+func incidentDetails(event Event) map[string]string {
+	details := map[string]string{
+		"target": event.Target,
+		"event":  event.Event,
+		"status": event.Status,
+		"time":   event.Time,
+	}
+	if event.URL != "" {
+		details["url"] = event.URL
+	}
+	if event.Error != "" {
+		details["error"] = event.Error
+	}
+	if event.Diff != "" {
+		details["diff"] = event.Diff
+	}
+	if len(event.Items) > 0 {
+		lines := make([]string, len(event.Items))
+		for i, it := range event.Items {
+			lines[i] = strings.TrimSpace(it.Title + " " + it.Link)
+		}
+		details["items"] = strings.Join(lines, "\n")
+	}
+	return details
+}
@@ -3,61 +3,180 @@ package notify
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"os"
 	"os/exec"
 	"strings"
 	"time"
 )
 
+// Event is a notification, and what channel templates are rendered
+// against.
 type Event struct {
-	Target   string `json:"target"`
-	URL      string `json:"url"`
-	Status   string `json:"status"`
-	OldHash  string `json:"old_hash,omitempty"`
-	NewHash  string `json:"new_hash,omitempty"`
-	Error    string `json:"error,omitempty"`
-	Time     string `json:"time"`
-	Message  string `json:"message"`
+	TargetID       int64    `json:"target_id,omitempty"`
+	Target         string   `json:"target"`
+	URL            string   `json:"url"`
+	Tags           []string `json:"tags,omitempty"`
+	Channel        string   `json:"channel,omitempty"` // name of the channel it is sent to
+	Event          string   `json:"event,omitempty"`   // down, degraded, recovery, reminder, changed, ssl_expiring
+	Status         string   `json:"status"`
+	PreviousStatus string   `json:"previous_status,omitempty"` // alert state before the event: up, down or degraded
+	StatusCode     int      `json:"status_code,omitempty"`
+	ResponseTime   int64    `json:"response_time_ms"`
+	SSLDays        *int     `json:"ssl_days,omitempty"`   // days until the certificate expires
+	SSLExpiry      string   `json:"ssl_expiry,omitempty"` // 2006-01-02
+	OldHash        string   `json:"old_hash,omitempty"`
+	NewHash        string   `json:"new_hash,omitempty"`
+	Error          string   `json:"error,omitempty"`
+	Downtime       string   `json:"downtime,omitempty"` // time spent down or degraded, for recovery and reminder events
+	Time           string   `json:"time"`
+	Message        string   `json:"message"`           // rendered by the channel's template
+	Summary        string   `json:"summary,omitempty"` // check type's summary of a change, e.g. a sitemap's added and removed URLs
+	Headers        []string `json:"headers,omitempty"` // changed response headers
+	Items          []Item   `json:"items,omitempty"`   // new entries of a feed target
+	Diff           string   `json:"diff,omitempty"`    // excerpt of the content change, "+ " and "- " lines
+	DiffSummary    string   `json:"diff_summary,omitempty"`
 }
 
-func Send(typ, config string, event Event) error {
+// Item is a feed entry reported in a changed event.
+type Item struct {
+	Title     string `json:"title"`
+	Link      string `json:"link,omitempty"`
+	Published string `json:"published,omitempty"`
+}
+
+// Message length limits of chat services; longer messages are rejected.
+const (
+	discordMaxLength  = 2000
+	telegramMaxLength = 4096
+)
+
+// client bounds notification requests, so an unresponsive service fails
+// the delivery instead of holding it up.
+var client = &http.Client{Timeout: 10 * time.Second}
+
+// Send renders an event with the channel's template and delivers it. It
+// returns the HTTP status code of the service, or 0 for channels that don't
+// use HTTP. When the channel's template failed and the event was sent with
+// the default one, the error is a *TemplateError.
+func Send(typ, config string, event Event) (int, error) {
+	r, tmplErr := Render(typ, config, event)
+	if r == nil {
+		return 0, tmplErr
+	}
+	event.Message = r.Message
+	code, err := send(typ, config, event, r)
+	if err != nil {
+		return code, err
+	}
+	return code, tmplErr
+}
+
+func send(typ, config string, event Event, r *Rendered) (int, error) {
 	switch typ {
 	case "webhook":
 		return sendWebhook(config, event)
 	case "command":
-		return sendCommand(config, event)
+		return 0, sendCommand(config, event)
 	case "slack":
 		return sendSlack(config, event)
 	case "telegram":
 		return sendTelegram(config, event)
 	case "discord":
 		return sendDiscord(config, event)
+	case "email":
+		return sendEmail(config, event, r)
+	case "pagerduty":
+		return sendPagerDuty(config, event)
+	case "opsgenie":
+		return sendOpsgenie(config, event)
 	default:
-		return fmt.Errorf("unknown notification type: %s", typ)
+		return 0, fmt.Errorf("unknown notification type: %s", typ)
 	}
 }
 
-func sendWebhook(configJSON string, event Event) error {
-	var cfg struct {
-		URL string `json:"url"`
+// Validate checks a channel's type, the config of types whose settings
+// can be checked without sending, and its templates by rendering a sample
+// event.
+func Validate(typ, config string) error {
+	var err error
+	switch typ {
+	case "webhook", "command", "slack", "telegram", "discord":
+	case "email":
+		_, err = parseEmailConfig(config)
+	case "pagerduty":
+		_, err = parsePagerDutyConfig(config)
+	case "opsgenie":
+		_, err = parseOpsgenieConfig(config)
+	default:
+		return fmt.Errorf("unknown notification type: %s (must be webhook, command, slack, telegram, discord, email, pagerduty or opsgenie)", typ)
 	}
-	if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+	if err != nil {
 		return err
 	}
+	// A template that fails on the sample is rejected rather than
+	// replaced by the default
+	_, err = Render(typ, config, SampleEvent("changed"))
+	var tmplErr *TemplateError
+	if errors.As(err, &tmplErr) {
+		return tmplErr.Err
+	}
+	return err
+}
 
-	body, _ := json.Marshal(event)
-	resp, err := http.Post(cfg.URL, "application/json", bytes.NewReader(body))
+// postJSON posts a JSON payload and returns the response status code. A
+// status of 400 or above is an error quoting the start of the response.
+func postJSON(service, url string, payload interface{}) (int, error) {
+	return postJSONWithHeader(service, url, nil, payload)
+}
+
+// postJSONWithHeader is postJSON with extra request headers, such as
+// credentials.
+func postJSONWithHeader(service, url string, header http.Header, payload interface{}) (int, error) {
+	body, err := json.Marshal(payload)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
 	}
-	resp.Body.Close()
+	defer resp.Body.Close()
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 200))
+		if msg := strings.TrimSpace(string(snippet)); msg != "" {
+			return resp.StatusCode, fmt.Errorf("%s returned %d: %s", service, resp.StatusCode, msg)
+		}
+		return resp.StatusCode, fmt.Errorf("%s returned %d", service, resp.StatusCode)
 	}
-	return nil
+	return resp.StatusCode, nil
 }
 
+func sendWebhook(configJSON string, event Event) (int, error) {
+	var cfg struct {
+		URL string `json:"url"`
+	}
+	if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+		return 0, err
+	}
+	return postJSON("webhook", cfg.URL, event)
+}
+
+// sendCommand runs the channel's command with sh. The event's fields are
+// passed in the environment, and placeholders expand to references to it:
+// the message carries text from monitored sites (feed titles, headers),
+// which must never be parsed as shell code.
 func sendCommand(configJSON string, event Event) error {
 	var cfg struct {
 		Command string `json:"command"`
@@ -66,72 +185,63 @@ func sendCommand(configJSON string, event Event) error {
 		return err
 	}
 
+	vars := []struct{ placeholder, env, value string }{
+		{"{target}", "UPP_TARGET", event.Target},
+		{"{url}", "UPP_URL", event.URL},
+		{"{status}", "UPP_STATUS", event.Status},
+		{"{message}", "UPP_MESSAGE", event.Message},
+	}
 	cmdStr := cfg.Command
-	cmdStr = strings.ReplaceAll(cmdStr, "{target}", event.Target)
-	cmdStr = strings.ReplaceAll(cmdStr, "{url}", event.URL)
-	cmdStr = strings.ReplaceAll(cmdStr, "{status}", event.Status)
-	cmdStr = strings.ReplaceAll(cmdStr, "{message}", event.Message)
+	env := append(os.Environ(), "UPP_EVENT="+event.Event)
+	for _, v := range vars {
+		cmdStr = strings.ReplaceAll(cmdStr, v.placeholder, "${"+v.env+"}")
+		env = append(env, v.env+"="+v.value)
+	}
 
 	cmd := exec.Command("sh", "-c", cmdStr)
-	return cmd.Run()
+	cmd.Env = env
+	if out, err := cmd.CombinedOutput(); err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+	return nil
 }
 
-func sendSlack(configJSON string, event Event) error {
+func sendSlack(configJSON string, event Event) (int, error) {
 	var cfg struct {
 		WebhookURL string `json:"webhook_url"`
 	}
 	if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
-		return err
+		return 0, err
 	}
-
-	payload := map[string]string{"text": event.Message}
-	body, _ := json.Marshal(payload)
-	resp, err := http.Post(cfg.WebhookURL, "application/json", bytes.NewReader(body))
-	if err != nil {
-		return err
-	}
-	resp.Body.Close()
-	return nil
+	return postJSON("slack", cfg.WebhookURL, map[string]string{"text": event.Message})
 }
 
-func sendTelegram(configJSON string, event Event) error {
+func sendTelegram(configJSON string, event Event) (int, error) {
 	var cfg struct {
 		BotToken string `json:"bot_token"`
 		ChatID   string `json:"chat_id"`
 	}
 	if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
-		return err
+		return 0, err
 	}
 
 	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", cfg.BotToken)
 	payload := map[string]string{
 		"chat_id": cfg.ChatID,
-		"text":    event.Message,
+		"text":    truncate(telegramMaxLength, event.Message),
 	}
-	body, _ := json.Marshal(payload)
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
-	if err != nil {
-		return err
-	}
-	resp.Body.Close()
-	return nil
+	return postJSON("telegram", url, payload)
 }
 
-func sendDiscord(configJSON string, event Event) error {
+func sendDiscord(configJSON string, event Event) (int, error) {
 	var cfg struct {
 		WebhookURL string `json:"webhook_url"`
 	}
 	if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
-		return err
-	}
-
-	payload := map[string]string{"content": event.Message}
-	body, _ := json.Marshal(payload)
-	resp, err := http.Post(cfg.WebhookURL, "application/json", bytes.NewReader(body))
-	if err != nil {
-		return err
+		return 0, err
 	}
-	resp.Body.Close()
-	return nil
+	return postJSON("discord", cfg.WebhookURL, map[string]string{"content": truncate(discordMaxLength, event.Message)})
 }
@@ -0,0 +1,87 @@
+package notify
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// commandChannel returns the config of a command channel that writes the
+// output of command to a file, and the file's path.
+func commandChannel(t *testing.T, command string) (config, out string) {
+	t.Helper()
+	out = filepath.Join(t.TempDir(), "out")
+	b, err := json.Marshal(map[string]string{"command": command + " > " + out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b), out
+}
+
+func TestSendCommandDoesNotRunEventText(t *testing.T) {
+	dir := t.TempDir()
+	pwned := filepath.Join(dir, "pwned")
+	message := "[upp] x is down: $(touch " + pwned + "); touch " + pwned + " `touch " + pwned + "` && 'quoted'"
+
+	for _, command := range []string{
+		`printf '%s' "{message}"`,
+		`printf '%s' {message}`,
+		`printf '%s' "$UPP_MESSAGE"`,
+	} {
+		config, out := commandChannel(t, command)
+		ev := SampleEvent("down")
+		ev.Message = message
+		if _, err := send("command", config, ev, nil); err != nil {
+			t.Fatalf("%s: %v", command, err)
+		}
+		if _, err := os.Stat(pwned); err == nil {
+			t.Fatalf("%s: ran a command from the message", command)
+		}
+		got, err := os.ReadFile(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Unquoted, the message is split into words but still not run
+		if command != `printf '%s' {message}` && string(got) != message {
+			t.Errorf("%s: got %q, want %q", command, got, message)
+		}
+	}
+}
+
+func TestSendCommandPlaceholders(t *testing.T) {
+	config, out := commandChannel(t, `echo "{target} ({url}) is {status}: $UPP_EVENT"`)
+	if _, err := send("command", config, SampleEvent("down"), nil); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "My Site (https://example.com) is down: down\n"; string(got) != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestSendCommandFeedTitle(t *testing.T) {
+	pwned := filepath.Join(t.TempDir(), "pwned")
+	title := `Release "1.0"; touch ` + pwned + ` $(touch ` + pwned + `) | rm -rf ~ & $HOME`
+
+	config, out := commandChannel(t, `printf '%s' "{message}"`)
+	ev := SampleEvent("changed")
+	ev.Items = []Item{{Title: title, Link: "https://example.com/1.0"}}
+	if _, err := Send("command", config, ev); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(pwned); err == nil {
+		t.Fatal("ran a command from a feed title")
+	}
+	got, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "• " + title + " — https://example.com/1.0"; !strings.Contains(string(got), want) {
+		t.Errorf("message lacks %q:\n%s", want, got)
+	}
+}
@@ -0,0 +1,102 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+)
+
+// opsgenieAPI is the Opsgenie API base URL; EU accounts use
+// https://api.eu.opsgenie.com.
+const opsgenieAPI = "https://api.opsgenie.com"
+
+// opsgeniePriorities maps incident severities to Opsgenie priorities.
+var opsgeniePriorities = map[string]string{
+	SeverityCritical: "P1",
+	SeverityError:    "P2",
+	SeverityWarning:  "P3",
+	SeverityInfo:     "P5",
+}
+
+type opsgenieConfig struct {
+	APIKey string   `json:"api_key"` // key of an API integration
+	Tags   []string `json:"tags"`
+	incidentConfig
+}
+
+func parseOpsgenieConfig(configJSON string) (*opsgenieConfig, error) {
+	var cfg opsgenieConfig
+	if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.APIKey == "" {
+		return nil, errors.New("opsgenie config needs an api_key")
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+type opsgenieAlert struct {
+	Message     string            `json:"message"`
+	Alias       string            `json:"alias"`
+	Description string            `json:"description,omitempty"`
+	Priority    string            `json:"priority"`
+	Source      string            `json:"source"`
+	Entity      string            `json:"entity,omitempty"`
+	Tags        []string          `json:"tags,omitempty"`
+	Details     map[string]string `json:"details,omitempty"`
+}
+
+// opsgenieNote is the body of an acknowledge or close request.
+type opsgenieNote struct {
+	Source string `json:"source"`
+	Note   string `json:"note,omitempty"`
+}
+
+// sendOpsgenie creates, acknowledges or closes the target's Opsgenie alert,
+// identified by its alias. A test event creates and then closes a test
+// alert.
+func sendOpsgenie(configJSON string, event Event) (int, error) {
+	cfg, err := parseOpsgenieConfig(configJSON)
+	if err != nil {
+		return 0, err
+	}
+	action := cfg.action(event)
+	if action == ActionIgnore {
+		return 0, nil
+	}
+	code, err := postOpsgenie(cfg, event, action)
+	if err != nil || event.Event != "test" || action != ActionTrigger {
+		return code, err
+	}
+	return postOpsgenie(cfg, event, ActionResolve)
+}
+
+func postOpsgenie(cfg *opsgenieConfig, event Event, action string) (int, error) {
+	header := http.Header{"Authorization": {"GenieKey " + cfg.APIKey}}
+	alias := cfg.dedupKey(event)
+	switch action {
+	case ActionAcknowledge, ActionResolve:
+		op := "acknowledge"
+		if action == ActionResolve {
+			op = "close"
+		}
+		path := "/v2/alerts/" + url.PathEscape(alias) + "/" + op + "?identifierType=alias"
+		return postJSONWithHeader("opsgenie", cfg.apiURL(opsgenieAPI, path), header, opsgenieNote{Source: "upp", Note: incidentSummary(event, 25000)})
+	}
+
+	alert := opsgenieAlert{
+		Message:     incidentSummary(event, 130),
+		Alias:       alias,
+		Description: emailText(event),
+		Priority:    opsgeniePriorities[cfg.severity(event)],
+		Source:      "upp",
+		Entity:      event.Target,
+		Tags:        cfg.Tags,
+		Details:     incidentDetails(event),
+	}
+	return postJSONWithHeader("opsgenie", cfg.apiURL(opsgenieAPI, "/v2/alerts"), header, alert)
+}
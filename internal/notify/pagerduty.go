@@ -0,0 +1,101 @@
+package notify
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// pagerDutyAPI is the PagerDuty Events API v2 base URL.
+const pagerDutyAPI = "https://events.pagerduty.com"
+
+type pagerDutyConfig struct {
+	RoutingKey string `json:"routing_key"` // integration key of an Events API v2 integration
+	incidentConfig
+}
+
+func parsePagerDutyConfig(configJSON string) (*pagerDutyConfig, error) {
+	var cfg pagerDutyConfig
+	if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.RoutingKey == "" {
+		return nil, errors.New("pagerduty config needs a routing_key")
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+type pagerDutyEvent struct {
+	RoutingKey  string            `json:"routing_key"`
+	EventAction string            `json:"event_action"`
+	DedupKey    string            `json:"dedup_key"`
+	Payload     *pagerDutyPayload `json:"payload,omitempty"`
+	Client      string            `json:"client,omitempty"`
+	Links       []pagerDutyLink   `json:"links,omitempty"`
+}
+
+type pagerDutyPayload struct {
+	Summary       string            `json:"summary"`
+	Source        string            `json:"source"`
+	Severity      string            `json:"severity"`
+	Timestamp     string            `json:"timestamp,omitempty"`
+	Component     string            `json:"component,omitempty"`
+	Class         string            `json:"class,omitempty"`
+	CustomDetails map[string]string `json:"custom_details,omitempty"`
+}
+
+type pagerDutyLink struct {
+	Href string `json:"href"`
+	Text string `json:"text"`
+}
+
+// sendPagerDuty sends the event to the PagerDuty Events API v2 as a
+// trigger, acknowledge or resolve of the target's incident. A test event
+// triggers and then resolves a test incident.
+func sendPagerDuty(configJSON string, event Event) (int, error) {
+	cfg, err := parsePagerDutyConfig(configJSON)
+	if err != nil {
+		return 0, err
+	}
+	action := cfg.action(event)
+	if action == ActionIgnore {
+		return 0, nil
+	}
+	code, err := postPagerDuty(cfg, event, action)
+	if err != nil || event.Event != "test" || action != ActionTrigger {
+		return code, err
+	}
+	return postPagerDuty(cfg, event, ActionResolve)
+}
+
+func postPagerDuty(cfg *pagerDutyConfig, event Event, action string) (int, error) {
+	pd := pagerDutyEvent{
+		RoutingKey:  cfg.RoutingKey,
+		EventAction: action,
+		DedupKey:    cfg.dedupKey(event),
+	}
+	// Only triggers carry the alert itself
+	if action == ActionTrigger {
+		source := event.URL
+		if source == "" {
+			source = event.Target
+		}
+		pd.Client = "upp"
+		pd.Payload = &pagerDutyPayload{
+			Summary:       incidentSummary(event, 1024),
+			Source:        source,
+			Severity:      cfg.severity(event),
+			Timestamp:     event.Time,
+			Component:     event.Target,
+			Class:         eventType(event),
+			CustomDetails: incidentDetails(event),
+		}
+		if strings.HasPrefix(event.URL, "http://") || strings.HasPrefix(event.URL, "https://") {
+			pd.Links = []pagerDutyLink{{Href: event.URL, Text: event.Target}}
+		}
+	}
+	return postJSON("pagerduty", cfg.apiURL(pagerDutyAPI, "/v2/enqueue"), pd)
+}
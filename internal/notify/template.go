@@ -0,0 +1,266 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// templateConfig holds the templates a channel's config may set. Each
+// replaces the channel type's built-in default.
+type templateConfig struct {
+	Template     string `json:"template"`      // text/template for the message
+	Subject      string `json:"subject"`       // text/template for an email's subject
+	HTMLTemplate string `json:"html_template"` // html/template for an email's HTML body
+}
+
+// Rendered is an event as a channel presents it.
+type Rendered struct {
+	Subject string `json:"subject,omitempty"` // email only
+	Message string `json:"message"`
+	HTML    string `json:"html,omitempty"` // email only
+}
+
+// templateFuncs are available in message templates, in addition to the
+// text/template builtins.
+var templateFuncs = template.FuncMap{
+	"join":     strings.Join,
+	"upper":    strings.ToUpper,
+	"lower":    strings.ToLower,
+	"trim":     strings.TrimSpace,
+	"truncate": truncate,
+	"datetime": datetime,
+}
+
+// truncate cuts s to max characters, ending it with "…" if it was longer.
+func truncate(max int, s string) string {
+	if r := []rune(s); len(r) > max && max > 0 {
+		return string(r[:max-1]) + "…"
+	}
+	return s
+}
+
+// datetime formats an RFC 3339 time as "2006-01-02 15:04", leaving other
+// strings as they are.
+func datetime(s string) string {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.Format("2006-01-02 15:04")
+	}
+	return s
+}
+
+// baseTemplates are shared by the built-in message templates: "message" is
+// the one-line summary of an event (plus feed entries and changed
+// headers), as every channel sent before templates existed.
+const baseTemplates = `
+{{- define "message" -}}
+{{- if eq .Event "test"}}[upp] Test notification for channel {{.Channel}}
+{{- else if .Items}}[upp] {{.Target}} ({{.URL}}) has {{len .Items}} new {{if eq (len .Items) 1}}entry{{else}}entries{{end}}:
+{{- range .Items}}
+• {{or .Title "(untitled)"}}{{with .Link}} — {{.}}{{end}}{{with .Published}} ({{datetime .}}){{end}}
+{{- end}}
+{{- else if eq .Event "recovery"}}[upp] {{.Target}} ({{.URL}}) recovered after {{.Downtime}}
+{{- else if eq .Event "reminder"}}[upp] {{.Target}} ({{.URL}}) is still {{.Status}} (for {{.Downtime}}){{with .Error}}: {{.}}{{end}}
+{{- else if eq .Event "ssl_expiring"}}[upp] {{.Target}} ({{.URL}}) certificate expires in {{.SSLDays}} days ({{.SSLExpiry}})
+{{- else}}[upp] {{.Target}} ({{.URL}}) is {{.Status}}{{with .Error}}: {{.}}{{end}}{{with .Summary}}: {{.}}{{end}}
+{{- with .Headers}}
+Headers:
+{{join . "\n"}}
+{{- end}}
+{{- end}}
+{{- end -}}
+`
+
+// defaultTemplates are the built-in message templates by channel type;
+// other types send the plain message.
+var defaultTemplates = map[string]string{
+	"slack": `{{template "message" .}}{{with .Diff}}
+` + "```" + `
+{{trim .}}
+` + "```" + `{{end}}`,
+	"discord": `{{template "message" .}}{{with .Diff}}
+` + "```diff" + `
+{{truncate 1500 (trim .)}}
+` + "```" + `{{end}}`,
+	"telegram": `{{template "message" .}}{{with .Diff}}
+
+{{truncate 3000 (trim .)}}{{end}}`,
+}
+
+const defaultTemplate = `{{template "message" .}}`
+
+// defaultSubject is the built-in subject of emails, e.g. "[upp] My Site is
+// down".
+const defaultSubject = `
+{{- if eq .Event "recovery"}}[upp] {{.Target}} recovered
+{{- else if eq .Event "reminder"}}[upp] {{.Target}} is still {{.Status}}
+{{- else if eq .Event "changed"}}[upp] {{.Target}} changed
+{{- else if eq .Event "ssl_expiring"}}[upp] {{.Target}} certificate expiring
+{{- else if eq .Event "test"}}[upp] Test notification
+{{- else}}[upp] {{.Target}} is {{.Status}}
+{{- end}}`
+
+// channelTemplates are a channel's parsed templates.
+type channelTemplates struct {
+	message *template.Template
+	subject *template.Template     // email only
+	html    *htmltemplate.Template // email only
+	custom  bool                   // any of them came from the config
+}
+
+// TemplateError reports that a channel's own template failed on an event,
+// which was rendered with the built-in templates instead.
+type TemplateError struct {
+	Err error
+}
+
+func (e *TemplateError) Error() string {
+	return "template failed, used the default: " + e.Err.Error()
+}
+
+func (e *TemplateError) Unwrap() error {
+	return e.Err
+}
+
+// parseTemplates parses the templates of a channel's config, falling back
+// to the channel type's defaults.
+func parseTemplates(typ, configJSON string) (*channelTemplates, error) {
+	var cfg templateConfig
+	if configJSON != "" {
+		if err := json.Unmarshal([]byte(configJSON), &cfg); err != nil {
+			return nil, err
+		}
+	}
+	text := cfg.Template
+	if text == "" {
+		text = defaultTemplates[typ]
+	}
+	if text == "" {
+		text = defaultTemplate
+	}
+	tmpl := &channelTemplates{
+		message: template.New("template").Funcs(templateFuncs),
+		custom:  cfg.Template != "" || cfg.Subject != "" || cfg.HTMLTemplate != "",
+	}
+	template.Must(tmpl.message.New("base").Parse(baseTemplates))
+	if _, err := tmpl.message.Parse(text); err != nil {
+		return nil, err
+	}
+	if typ != "email" {
+		return tmpl, nil
+	}
+
+	subject := cfg.Subject
+	if subject == "" {
+		subject = defaultSubject
+	}
+	var err error
+	if tmpl.subject, err = template.New("subject").Funcs(templateFuncs).Parse(subject); err != nil {
+		return nil, err
+	}
+	tmpl.html = emailHTML
+	if cfg.HTMLTemplate != "" {
+		if tmpl.html, err = htmltemplate.New("html_template").Funcs(htmltemplate.FuncMap(templateFuncs)).Parse(cfg.HTMLTemplate); err != nil {
+			return nil, err
+		}
+	}
+	return tmpl, nil
+}
+
+// render renders an event with the channel's templates. An email's
+// subject and HTML body see the rendered message as .Message.
+func (t *channelTemplates) render(event Event) (*Rendered, error) {
+	var buf bytes.Buffer
+	if err := t.message.Execute(&buf, event); err != nil {
+		return nil, err
+	}
+	r := &Rendered{Message: strings.TrimSpace(buf.String())}
+	if t.subject == nil {
+		return r, nil
+	}
+
+	event.Message = r.Message
+	buf.Reset()
+	if err := t.subject.Execute(&buf, event); err != nil {
+		return nil, err
+	}
+	// A subject is a single header line
+	r.Subject = strings.Join(strings.Fields(buf.String()), " ")
+
+	buf.Reset()
+	data := emailData{Event: event, Subject: r.Subject, DiffLines: diffLines(event.Diff)}
+	if err := t.html.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	r.HTML = buf.String()
+	return r, nil
+}
+
+// Render renders an event the way a channel would send it, without
+// sending it. A template that validated against a sample can still fail on
+// real data (say, indexing the tags of an untagged target); the event is
+// then rendered with the built-in templates, returned along with a
+// *TemplateError, so the alert still goes out.
+func Render(typ, config string, event Event) (*Rendered, error) {
+	tmpl, err := parseTemplates(typ, config)
+	if err != nil {
+		return nil, err
+	}
+	r, err := tmpl.render(event)
+	if err == nil || !tmpl.custom {
+		return r, err
+	}
+	defaults, _ := parseTemplates(typ, "")
+	if r, defErr := defaults.render(event); defErr == nil {
+		return r, &TemplateError{Err: err}
+	}
+	return nil, err
+}
+
+// SampleEvent returns an example event of the given type, for previews and
+// for checking templates.
+func SampleEvent(event string) Event {
+	days := 12
+	ev := Event{
+		TargetID:       1,
+		Target:         "My Site",
+		URL:            "https://example.com",
+		Tags:           []string{"production"},
+		Event:          event,
+		Status:         "down",
+		PreviousStatus: "up",
+		ResponseTime:   5003,
+		Error:          "connection timed out",
+		SSLDays:        &days,
+		SSLExpiry:      time.Now().AddDate(0, 0, days).UTC().Format("2006-01-02"),
+		Time:           time.Now().UTC().Format(time.RFC3339),
+	}
+	switch event {
+	case "error":
+		ev.Event, ev.Status = "down", "error"
+		ev.Error = "dial tcp: lookup example.com: no such host"
+	case "degraded":
+		// Only ping targets degrade, on packet loss
+		ev.URL, ev.Status, ev.ResponseTime = "example.com", "degraded", 48
+		ev.Error = "packet loss 40% (3/5 replies)"
+	case "reminder":
+		ev.StatusCode, ev.Downtime = 503, "1h0m0s"
+		ev.Error = "HTTP 503"
+	case "recovery":
+		ev.Status, ev.PreviousStatus, ev.StatusCode, ev.ResponseTime = "up", "down", 200, 182
+		ev.Error, ev.Downtime = "", "12m30s"
+	case "changed":
+		ev.Status, ev.PreviousStatus, ev.StatusCode, ev.ResponseTime = "changed", "up", 200, 182
+		ev.Error = ""
+		ev.Diff = "- Price: $10\n+ Price: $12\n"
+		ev.DiffSummary = "1 added, 1 removed"
+	case "ssl_expiring":
+		ev.Status, ev.StatusCode, ev.ResponseTime, ev.Error = "up", 200, 182, ""
+	case "test":
+		ev.Target, ev.URL, ev.Tags, ev.Status, ev.Error = "upp", "", nil, "up", ""
+	}
+	return ev
+}
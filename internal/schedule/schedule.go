@@ -0,0 +1,70 @@
+package schedule
+
+import (
+	"hash/fnv"
+	"strconv"
+	"time"
+)
+
+// catchUpWindow bounds how long overdue targets are spread over when the
+// daemon starts after being stopped for longer than their interval.
+const catchUpWindow = 30 * time.Second
+
+// Offset returns a deterministic per-target offset within the interval, so
+// that targets sharing an interval are spread out instead of firing together.
+func Offset(targetID int64, interval time.Duration) time.Duration {
+	if interval <= 0 {
+		return 0
+	}
+	h := fnv.New64a()
+	h.Write([]byte(strconv.FormatInt(targetID, 10)))
+	return time.Duration(h.Sum64() % uint64(interval))
+}
+
+// First returns the first run time for a target that has never been
+// scheduled: the next slot in the target's fixed phase within its interval.
+func First(targetID int64, interval time.Duration, now time.Time) time.Time {
+	if interval <= 0 {
+		return now
+	}
+	slot := now.Truncate(interval).Add(Offset(targetID, interval))
+	if slot.Before(now) {
+		slot = slot.Add(interval)
+	}
+	return slot
+}
+
+// Resume returns when a target should run given its persisted next run time.
+// Future times are kept as-is; overdue targets are spread over a short
+// catch-up window rather than all running in the first tick.
+func Resume(targetID int64, interval time.Duration, next *time.Time, now time.Time) time.Time {
+	if next == nil {
+		return First(targetID, interval, now)
+	}
+	// The interval was shortened since this run was scheduled
+	if next.Sub(now) > interval {
+		return First(targetID, interval, now)
+	}
+	if !next.Before(now) {
+		return *next
+	}
+	window := catchUpWindow
+	if interval < window {
+		window = interval
+	}
+	return now.Add(Offset(targetID, window))
+}
+
+// Next returns the run that follows one scheduled at prev. Runs are anchored
+// to the schedule rather than to when the check finished, so intervals don't
+// drift; runs missed while the check was queued are skipped.
+func Next(interval time.Duration, prev, now time.Time) time.Time {
+	if interval <= 0 {
+		return now
+	}
+	next := prev.Add(interval)
+	if !next.After(now) {
+		next = next.Add((now.Sub(next)/interval + 1) * interval)
+	}
+	return next
+}
@@ -3,14 +3,16 @@ package trigger
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
 // Rule defines a trigger condition for notifications.
 type Rule struct {
-	Type  string `json:"type"`  // contains, not_contains, regex, not_regex
-	Value string `json:"value"` // text or regex pattern
+	Type  string `json:"type"`  // contains, not_contains, regex, not_regex, above, below, drop, rise
+	Value string `json:"value"` // text, regex pattern, number or change ("10%" or "5")
 }
 
 // ParseShorthand parses "type:value" shorthand into a JSON rule string.
@@ -25,15 +27,25 @@ func ParseShorthand(input string) (string, error) {
 	val := input[idx+1:]
 
 	switch typ {
-	case "contains", "not_contains", "regex", "not_regex":
+	case "contains", "not_contains", "regex", "not_regex", "above", "below", "drop", "rise":
 	default:
-		return "", fmt.Errorf("unknown trigger type %q (valid: contains, not_contains, regex, not_regex)", typ)
+		return "", fmt.Errorf("unknown trigger type %q (valid: contains, not_contains, regex, not_regex, above, below, drop, rise)", typ)
 	}
 
 	if val == "" {
 		return "", fmt.Errorf("trigger value cannot be empty")
 	}
 
+	if IsValueRule(typ) {
+		_, percent, err := parseAmount(val)
+		if err != nil {
+			return "", err
+		}
+		if percent && (typ == "above" || typ == "below") {
+			return "", fmt.Errorf("%s takes a number, not a percentage", typ)
+		}
+	}
+
 	// Validate regex if applicable
 	if typ == "regex" || typ == "not_regex" {
 		if _, err := regexp.Compile(val); err != nil {
@@ -80,6 +92,76 @@ func Evaluate(ruleJSON string, content string) (bool, error) {
 	}
 }
 
+// IsValueRule reports whether a rule type compares numeric values rather
+// than content.
+func IsValueRule(typ string) bool {
+	switch typ {
+	case "above", "below", "drop", "rise":
+		return true
+	}
+	return false
+}
+
+// RuleType returns the type of a rule, or "" if it cannot be decoded.
+func RuleType(ruleJSON string) string {
+	var r Rule
+	if err := json.Unmarshal([]byte(ruleJSON), &r); err != nil {
+		return ""
+	}
+	return r.Type
+}
+
+// EvaluateValue checks a numeric rule against a tracked value. prev is the
+// value recorded by the previous check, nil if there is none; drop and rise
+// rules never fire without one.
+func EvaluateValue(ruleJSON string, prev *float64, cur float64) (bool, error) {
+	var r Rule
+	if err := json.Unmarshal([]byte(ruleJSON), &r); err != nil {
+		return true, fmt.Errorf("invalid trigger rule JSON: %w", err)
+	}
+	amount, percent, err := parseAmount(r.Value)
+	if err != nil {
+		return true, err
+	}
+
+	switch r.Type {
+	case "above":
+		return cur > amount, nil
+	case "below":
+		return cur < amount, nil
+	case "drop", "rise":
+		if prev == nil {
+			return false, nil
+		}
+		change := cur - *prev
+		if r.Type == "drop" {
+			change = -change
+		}
+		if percent {
+			if *prev == 0 {
+				return false, nil
+			}
+			change = change / math.Abs(*prev) * 100
+		}
+		return change >= amount, nil
+	default:
+		return true, fmt.Errorf("unknown trigger type: %s", r.Type)
+	}
+}
+
+// parseAmount parses a number, or a percentage such as "10%".
+func parseAmount(s string) (amount float64, percent bool, err error) {
+	s = strings.TrimSpace(s)
+	if trimmed, ok := strings.CutSuffix(s, "%"); ok {
+		s, percent = strings.TrimSpace(trimmed), true
+	}
+	amount, err = strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false, fmt.Errorf("invalid number %q in trigger rule", s)
+	}
+	return amount, percent, nil
+}
+
 // Describe returns a human-readable description of the trigger rule.
 func Describe(ruleJSON string) string {
 	if ruleJSON == "" {
@@ -98,6 +180,14 @@ func Describe(ruleJSON string) string {
 		return fmt.Sprintf("trigger if matches /%s/", r.Value)
 	case "not_regex":
 		return fmt.Sprintf("trigger if not matches /%s/", r.Value)
+	case "above":
+		return fmt.Sprintf("trigger if value above %s", r.Value)
+	case "below":
+		return fmt.Sprintf("trigger if value below %s", r.Value)
+	case "drop":
+		return fmt.Sprintf("trigger if value drops by %s", r.Value)
+	case "rise":
+		return fmt.Sprintf("trigger if value rises by %s", r.Value)
 	default:
 		return ruleJSON
 	}